@@ -1,17 +1,112 @@
+/*
+Shows the video from a camera in a window, until ESC is pressed or the
+camera stops sending frames.
+
+How to run
+
+	go run ./hellovideo [camera ID]
+
+The camera ID defaults to 0, the first camera.
+*/
 package main
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
 	"gocv.io/x/gocv"
 )
 
+// maxFailedReads is how many frames in a row the camera may fail to send
+// before it is taken to have stopped.
+const maxFailedReads = 10
+
+// escKey is what gocv.WaitKey returns for ESC.
+const escKey = 27
+
 func main() {
-	webcam, _ := gocv.VideoCaptureDevice(1)
+	deviceID, err := parseDevice(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("How to run:\n\thellovideo [camera ID]")
+		os.Exit(1)
+	}
+
+	webcam, err := gocv.VideoCaptureDevice(deviceID)
+	if err != nil {
+		fmt.Printf("error opening video capture device %v: %v\n", deviceID, err)
+		os.Exit(1)
+	}
+	defer webcam.Close()
+
 	window := gocv.NewWindow("Hello")
+	defer window.Close()
+
 	img := gocv.NewMat()
+	defer img.Close()
 
-	for {
-		webcam.Read(img)
+	err = showVideo(captureReader{webcam}, &img, func(img gocv.Mat) bool {
 		window.IMShow(img)
-		gocv.WaitKey(1)
+		return gocv.WaitKey(1) == escKey
+	}, func() {
+		fmt.Printf("cannot read from device %v\n", deviceID)
+	})
+	if err != nil {
+		fmt.Printf("device %v: %v\n", deviceID, err)
+	}
+}
+
+// parseDevice returns the camera ID given in args, or 0 if there is none.
+func parseDevice(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	if len(args) > 1 {
+		return 0, fmt.Errorf("too many arguments: %v", args)
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("camera ID %q is not a device number", args[0])
+	}
+	return id, nil
+}
+
+// frameReader reads the next frame into img, reporting whether there was
+// one.
+type frameReader interface {
+	read(img *gocv.Mat) bool
+}
+
+// captureReader reads frames from a camera, taking an empty frame as a
+// failed read.
+type captureReader struct {
+	capture *gocv.VideoCapture
+}
+
+func (c captureReader) read(img *gocv.Mat) bool {
+	return c.capture.Read(img) && !img.Empty()
+}
+
+// showVideo reads frames from r into img and shows each one, until show
+// returns true, when it returns nil. Each failed read is reported with
+// failed, and after maxFailedReads of them in a row it gives up with an
+// error.
+func showVideo(r frameReader, img *gocv.Mat, show func(img gocv.Mat) bool, failed func()) error {
+	failures := 0
+	for {
+		if !r.read(img) {
+			failed()
+			failures++
+			if failures >= maxFailedReads {
+				return fmt.Errorf("stopped sending frames after %d failed reads", failures)
+			}
+			continue
+		}
+		failures = 0
+
+		if show(*img) {
+			return nil
+		}
 	}
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestParseDevice(t *testing.T) {
+	tests := []struct {
+		args []string
+		id   int
+		ok   bool
+	}{
+		{nil, 0, true},
+		{[]string{"0"}, 0, true},
+		{[]string{"2"}, 2, true},
+		{[]string{"-1"}, 0, false},
+		{[]string{"webcam"}, 0, false},
+		{[]string{"1", "2"}, 0, false},
+	}
+	for _, test := range tests {
+		id, err := parseDevice(test.args)
+		if (err == nil) != test.ok {
+			t.Errorf("parseDevice(%q) error %v, want ok %v", test.args, err, test.ok)
+			continue
+		}
+		if id != test.id {
+			t.Errorf("parseDevice(%q) = %d, want %d", test.args, id, test.id)
+		}
+	}
+}
+
+// stubReader returns each of reads in turn, then fails.
+type stubReader struct {
+	reads []bool
+}
+
+func (s *stubReader) read(img *gocv.Mat) bool {
+	if len(s.reads) == 0 {
+		return false
+	}
+	ok := s.reads[0]
+	s.reads = s.reads[1:]
+	return ok
+}
+
+func TestShowVideo(t *testing.T) {
+	var (
+		fails []bool
+		later []bool
+	)
+	for i := 0; i < maxFailedReads-1; i++ {
+		fails = append(fails, false)
+	}
+	for i := 0; i < 3; i++ {
+		later = append(later, true)
+	}
+
+	tests := []struct {
+		name   string
+		reads  []bool
+		quitAt int
+		shown  int
+		failed int
+		err    bool
+	}{
+		{name: "never reads", failed: maxFailedReads, err: true},
+		{name: "recovers then stops", reads: append(append(fails, true), fails...), shown: 1, failed: maxFailedReads - 1 + maxFailedReads, err: true},
+		{name: "esc", reads: later, quitAt: 2, shown: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shown, failed := 0, 0
+			err := showVideo(&stubReader{reads: test.reads}, &gocv.Mat{}, func(gocv.Mat) bool {
+				shown++
+				return shown == test.quitAt
+			}, func() { failed++ })
+
+			if (err != nil) != test.err {
+				t.Errorf("error %v, want error %v", err, test.err)
+			}
+			if shown != test.shown {
+				t.Errorf("showed %d frames, want %d", shown, test.shown)
+			}
+			if failed != test.failed {
+				t.Errorf("reported %d failed reads, want %d", failed, test.failed)
+			}
+		})
+	}
+}
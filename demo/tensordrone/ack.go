@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var ackTimeout = flag.Duration("ack-timeout", 3*time.Second, "warn when the drone has not reported acting on a takeoff, landing, flat trim or emergency command after this long, 0 for off")
+
+// ackShown is how long a missing acknowledgement stays on screen.
+const ackShown = 5 * time.Second
+
+// ackTracker matches the commands that matter most with the state changes
+// the drone reports when it acts on them. BLE commands get no reply of
+// their own, so a state change is the only sign that one got through.
+type ackTracker struct {
+	sync.Mutex
+	timeout time.Duration
+	log     *timeline
+	pending map[string]pendingAck
+
+	warning  string
+	warnedAt time.Time
+}
+
+// pendingAck is a command waiting for any one of events.
+type pendingAck struct {
+	sent   time.Time
+	events []string
+}
+
+func newAckTracker(timeout time.Duration, log *timeline) *ackTracker {
+	return &ackTracker{timeout: timeout, log: log, pending: make(map[string]pendingAck)}
+}
+
+// expect records that command was sent at now, and that the drone should
+// report one of events once it acts on it. Sending it again starts over.
+func (a *ackTracker) expect(command string, now time.Time, events ...string) {
+	if a.timeout <= 0 {
+		return
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	a.pending[command] = pendingAck{sent: now, events: events}
+}
+
+// confirm records the drone reporting event, acknowledging any commands
+// waiting for it.
+func (a *ackTracker) confirm(event string, now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+
+	for command, p := range a.pending {
+		for _, e := range p.events {
+			if e == event {
+				took := now.Sub(p.sent)
+				a.log.record("ack", "%v after %v", command, took)
+				log.Printf("drone acknowledged %v after %v", command, took.Round(time.Millisecond))
+				delete(a.pending, command)
+				break
+			}
+		}
+	}
+}
+
+// check warns about any command that has waited longer than the timeout.
+func (a *ackTracker) check(now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+
+	for command, p := range a.pending {
+		if now.Sub(p.sent) < a.timeout {
+			continue
+		}
+		a.warning = fmt.Sprintf("warning: no response to %v", command)
+		a.warnedAt = now
+		a.log.record("ack", "%v not acknowledged", command)
+		log.Printf("drone has not acknowledged %v after %v", command, a.timeout)
+		delete(a.pending, command)
+	}
+}
+
+// status returns the last missing acknowledgement for the overlay, for a
+// while after it was noticed.
+func (a *ackTracker) status(now time.Time) string {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.warning == "" || now.Sub(a.warnedAt) > ackShown {
+		return ""
+	}
+	return a.warning
+}
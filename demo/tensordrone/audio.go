@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	audio           = flag.Bool("audio", false, "play sounds for flight events and newly seen classifications")
+	audioDir        = flag.String("audio-dir", "sounds", "directory of WAV files named after events (takeoff.wav, land.wav) or labels")
+	audioConfidence = flag.Float64("audio-confidence", 0.5, "minimum score before a classification is announced")
+)
+
+// confidence is the minimum score for announcing a classification, which
+// starts at -audio-confidence and can be changed from the menu.
+var confidence atomic.Value
+
+// soundPlayer plays short WAV files by handing them to whichever command
+// line audio player is available.
+type soundPlayer struct {
+	dir    string
+	player string
+
+	playing int32
+
+	sync.Mutex
+	lastLabel string
+}
+
+// newSoundPlayer returns a player for the WAV files in dir, or nil if
+// there is no audio player to use.
+func newSoundPlayer(dir string) *soundPlayer {
+	for _, name := range []string{"aplay", "paplay", "afplay"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return &soundPlayer{dir: dir, player: path}
+		}
+	}
+	log.Println("audio: no player found, install aplay, paplay or afplay")
+	return nil
+}
+
+// play plays the sound called name, if there is one. Sounds do not overlap,
+// so one that would start while another is still playing is dropped.
+func (p *soundPlayer) play(name string) {
+	if p == nil {
+		return
+	}
+
+	file := filepath.Join(p.dir, soundFileName(name))
+	if _, err := os.Stat(file); err != nil {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&p.playing, 0, 1) {
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&p.playing, 0)
+		if err := exec.Command(p.player, file).Run(); err != nil {
+			log.Printf("audio: %v: %v", file, err)
+		}
+	}()
+}
+
+// announce plays the sound for label when the classification changes to
+// it with at least the given confidence.
+func (p *soundPlayer) announce(label string, score, confidence float32) {
+	if p == nil || score < confidence {
+		return
+	}
+
+	p.Lock()
+	changed := label != p.lastLabel
+	p.lastLabel = label
+	p.Unlock()
+
+	if changed {
+		p.play(label)
+	}
+}
+
+// soundFileName turns an event or label into the name of its WAV file,
+// so that "golden retriever" is looked up as golden_retriever.wav.
+func soundFileName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer(" ", "_", "/", "_", ",", "").Replace(name)
+	return name + ".wav"
+}
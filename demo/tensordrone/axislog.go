@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var logAxesPath = flag.String("log-axes", "", "append every joystick axis value with a timestamp to this file, to diagnose drift")
+
+// restRange is how far from center, as a fraction of the controls offset,
+// an axis value still counts as the stick being left alone rather than
+// pushed.
+const restRange = 0.25
+
+// axisLog records raw joystick axis values. Analyse one with the axes
+// command to find out how much a controller drifts.
+type axisLog struct {
+	sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// openAxisLog appends to the log at path, or returns nil if path is empty.
+func openAxisLog(path string) (*axisLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &axisLog{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// record adds an axis value to the log.
+func (a *axisLog) record(axis string, val float64) {
+	if a == nil {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	fmt.Fprintf(a.w, "%d,%v,%.0f\n", time.Now().UnixNano()/int64(time.Millisecond), axis, val)
+}
+
+// Close flushes and closes the log.
+func (a *axisLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	if err := a.w.Flush(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+// reportAxes reads the axis log at path and prints, for each axis, where it
+// rests and the deadzone needed to hide its drift.
+func reportAxes(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 3
+	rest := make(map[string][]float64)
+	counts := make(map[string]int)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		val, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil {
+			return err
+		}
+
+		// only values near center tell us about drift, the rest are the
+		// stick being pushed on purpose
+		counts[rec[1]]++
+		if math.Abs(val) < restRange*controls.Offset {
+			rest[rec[1]] = append(rest[rec[1]], val)
+		}
+	}
+
+	axes := make([]string, 0, len(counts))
+	for axis := range counts {
+		axes = append(axes, axis)
+	}
+	sort.Strings(axes)
+
+	fmt.Fprintf(w, "%-12s %8s %8s %8s %9s\n", "axis", "samples", "offset", "drift", "deadzone")
+	for _, axis := range axes {
+		vals := rest[axis]
+		if len(vals) == 0 {
+			fmt.Fprintf(w, "%-12s %8d %8s %8s %9s\n", axis, counts[axis], "-", "-", "-")
+			continue
+		}
+
+		// the median is where the stick rests, ignoring the odd nudge
+		sort.Float64s(vals)
+		center := vals[len(vals)/2]
+		var drift float64
+		for _, v := range vals {
+			drift = math.Max(drift, math.Abs(v-center))
+		}
+
+		// suggest a deadzone just beyond the furthest drift from zero
+		deadzone := math.Ceil((math.Abs(center)+drift)/controls.Offset*100) + 1
+		fmt.Fprintf(w, "%-12s %8d %8.0f %8.0f %8.0f%%\n", axis, counts[axis], center, drift, deadzone)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	bannerFile = flag.String("banner", "", "text file shown over the video at startup, one line per line, such as the demo name, credits and attributions")
+	bannerTime = flag.Duration("banner-time", 5*time.Second, "how long the -banner is shown for, unless a button is pressed first")
+)
+
+// bannerScale is the text size of the banner, the first line of which is
+// shown twice as large as a title.
+const bannerScale = 2.0
+
+// banner shows some lines of text over the video for a while after the
+// demo starts. It is nil when there is no -banner, and then never shows.
+type banner struct {
+	lines []string
+
+	sync.Mutex
+	until time.Time
+}
+
+// readBanner reads the banner text from path, returning nil if path is
+// empty. It is shown from when start is called.
+func readBanner(path string) (*banner, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := &banner{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		b.lines = append(b.lines, strings.TrimRight(scanner.Text(), " \t"))
+	}
+	return b, scanner.Err()
+}
+
+// start shows the banner for d from now.
+func (b *banner) start(now time.Time, d time.Duration) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	b.until = now.Add(d)
+}
+
+// showing reports whether the banner is being shown.
+func (b *banner) showing(now time.Time) bool {
+	if b == nil {
+		return false
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	return now.Before(b.until)
+}
+
+// skip stops showing the banner.
+func (b *banner) skip() {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	b.until = time.Time{}
+}
+
+// draw darkens img and writes the banner lines across the middle of it.
+func (b *banner) draw(img *gocv.Mat) {
+	img.ConvertToWithParams(img, img.Type(), 0.3, 0)
+
+	white := color.RGBA{255, 255, 255, 0}
+	sizes := make([]image.Point, len(b.lines))
+	height := 0
+	for i, line := range b.lines {
+		sizes[i] = gocv.GetTextSize(line, overlayFont, bannerLineScale(i), overlayThickness)
+		height += sizes[i].Y + 2*overlaySpacing
+	}
+
+	y := (img.Rows() - height) / 2
+	for i, line := range b.lines {
+		y += sizes[i].Y + overlaySpacing
+		x := (img.Cols() - sizes[i].X) / 2
+		gocv.PutText(img, line, image.Pt(x, y), overlayFont, bannerLineScale(i), white, overlayThickness)
+		y += overlaySpacing
+	}
+}
+
+// bannerLineScale is the text size of line i of the banner.
+func bannerLineScale(i int) float64 {
+	if i == 0 {
+		return 2 * bannerScale
+	}
+	return bannerScale
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+var (
+	batteryCompensate = flag.Float64("battery-compensate", 0, "scale commands up by as much as this fraction, such as 0.3, as the battery drains, to keep the same feel (0 for off)")
+	batteryStart      = flag.Int("battery-start", 80, "battery percent below which -battery-compensate starts scaling commands up")
+	batteryEmpty      = flag.Int("battery-empty", 20, "battery percent at which -battery-compensate is at its most and can do no more")
+	batterySmoothing  = flag.Float64("battery-smoothing", 0.2, "how much each battery report moves the battery level, from 0 to 1, smoothing out dips under motor load (1 for off)")
+)
+
+// batteryLevel is the battery percent reported by the drone, smoothed by
+// -battery-smoothing, or -1 before it has reported one.
+var batteryLevel int32 = -1
+
+// batterySmoothed is the smoothed battery level before it is rounded. It
+// is only used by batteryReported, which the drone's battery events call
+// one at a time.
+var batterySmoothed float64
+
+// batteryReported smooths a battery percent reported by the drone into
+// batteryLevel, with an exponential moving average. Under load the
+// reported level dips and recovers, which would otherwise make the overlay
+// flicker and trip the low battery warning early.
+func batteryReported(percent uint8) {
+	alpha := *batterySmoothing
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	if atomic.LoadInt32(&batteryLevel) < 0 {
+		// nothing to smooth against yet
+		batterySmoothed = float64(percent)
+	} else {
+		batterySmoothed += alpha * (float64(percent) - batterySmoothed)
+	}
+	atomic.StoreInt32(&batteryLevel, int32(math.Round(batterySmoothed)))
+}
+
+// batteryGain returns how much to scale commands by at the given battery
+// level. It rises in a straight line from 1 at start to 1+max at empty.
+func batteryGain(level, start, empty int, max float64) float64 {
+	switch {
+	case max <= 0 || level < 0 || level >= start:
+		return 1
+	case level <= empty:
+		return 1 + max
+	}
+	return 1 + max*float64(start-level)/float64(start-empty)
+}
+
+// batteryCompensated scales cmd up for the current battery level. The
+// result is still capped by the preset limit in scaleCommand.
+func batteryCompensated(cmd int) int {
+	level := int(atomic.LoadInt32(&batteryLevel))
+	return int(float64(cmd) * batteryGain(level, *batteryStart, *batteryEmpty, *batteryCompensate))
+}
+
+// batteryStatus describes the battery for the overlay, and whether it is
+// now too low for -battery-compensate to keep up.
+func batteryStatus() (string, bool) {
+	level := atomic.LoadInt32(&batteryLevel)
+	switch {
+	case level < 0:
+		return "", false
+	case *batteryCompensate > 0 && int(level) <= *batteryEmpty:
+		return fmt.Sprintf("battery: %d%%, too low to compensate", level), true
+	}
+	return fmt.Sprintf("battery: %d%%", level), false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gobot.io/x/gobot"
+)
+
+var bindingsFlag = flag.String("bindings", "", "rebind actions to buttons, such as stop=square,takeoff=triangle,land=x,emergency=circle")
+
+// defaultBindings maps each action to the button that triggers it. An
+// action bound to "" has no button.
+var defaultBindings = map[string]string{
+	"arm":       "r1",
+	"stop":      "square",
+	"takeoff":   "triangle",
+	"land":      "x",
+	"emergency": "circle",
+	"hull":      "select",
+	"power":     "l1",
+	"classify":  "right_stick",
+	"pause":     "home",
+	"bad":       "left",
+	"good":      "right",
+	"trim":      "up",
+	"lights":    "down",
+	"menu":      "start",
+	"orbit":     "left_stick",
+	"camera":    "",
+	"record":    "",
+	"lock":      "",
+	"reload":    "",
+	"follow":    "",
+
+	"layer-classification": "",
+	"layer-regions":        "",
+	"layer-status":         "",
+	"layer-battery":        "",
+	"layer-commands":       "",
+	"layer-history":        "",
+}
+
+// parseBindings applies the comma separated action=button pairs in spec on
+// top of base, such as the default bindings, returning a table of action
+// to button.
+func parseBindings(base map[string]string, spec string) (map[string]string, error) {
+	bindings := make(map[string]string, len(base))
+	for action, button := range base {
+		bindings[action] = button
+	}
+	if strings.TrimSpace(spec) == "" {
+		return bindings, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("binding %q is not in the form action=button", pair)
+		}
+
+		action := strings.ToLower(strings.TrimSpace(parts[0]))
+		button := strings.ToLower(strings.TrimSpace(parts[1]))
+		if _, ok := defaultBindings[action]; !ok {
+			return nil, fmt.Errorf("unknown action %q, expected one of %v", action, strings.Join(actionNames(), ", "))
+		}
+		if button == "" {
+			return nil, fmt.Errorf("no button given for action %q", action)
+		}
+		bindings[action] = button
+	}
+
+	// a button can only trigger one action
+	seen := make(map[string]string)
+	for _, action := range actionNames() {
+		button := bindings[action]
+		if button == "" {
+			continue
+		}
+		if other, ok := seen[button]; ok {
+			return nil, fmt.Errorf("button %q is bound to both %v and %v", button, other, action)
+		}
+		seen[button] = action
+	}
+	return bindings, nil
+}
+
+// actionNames returns the names of all actions that can be bound, sorted.
+func actionNames() []string {
+	names := make([]string, 0, len(defaultBindings))
+	for action := range defaultBindings {
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pressEvent returns the joystick event sent when button is pressed.
+func pressEvent(button string) string {
+	return button + "_press"
+}
+
+// onButtons runs the action bound to each button in buttons when stick
+// sends its press. While the menu is open the d-pad drives it instead of
+// the drone, so the d-pad buttons go to menu first, which reports whether
+// it took the press.
+func onButtons(stick gobot.Eventer, buttons map[string]string, actions map[string]func(), menu func(button string) bool) {
+	dpad := map[string]func(){"up": nil, "down": nil, "left": nil, "right": nil}
+	for action, button := range buttons {
+		if button == "" {
+			continue
+		}
+		do := actions[action]
+		if _, ok := dpad[button]; ok {
+			dpad[button] = do
+			continue
+		}
+		stick.On(pressEvent(button), func(data interface{}) {
+			do()
+		})
+	}
+	for button, do := range dpad {
+		button, do := button, do
+		stick.On(pressEvent(button), func(data interface{}) {
+			if !menu(button) && do != nil {
+				do()
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// benchBlobs times turning a frame into a network input blob with
+// BlobFromImage against doing the same by hand, with a resize, a color
+// conversion and a copy into the blob, to see which is quicker on this
+// machine. It checks the two give the same blob before timing them.
+func benchBlobs(args []string, w io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("How to run:\n\ttensordrone [flags] blobbench [iterations] [imagefile]")
+	}
+	iterations, err := strconv.Atoi(args[0])
+	if err != nil || iterations < 1 {
+		return fmt.Errorf("iterations must be a positive number, not %v", args[0])
+	}
+	if err := checkFile("image", args[1]); err != nil {
+		return err
+	}
+	img := gocv.IMRead(args[1], gocv.IMReadColor)
+	if img.Empty() {
+		return fmt.Errorf("could not read image %v", args[1])
+	}
+	defer img.Close()
+
+	builtin := func() gocv.Mat {
+		return gocv.BlobFromImage(img, 1.0, blobSize, gocv.NewScalar(0, 0, 0, 0), swapRB.or(true), *crop)
+	}
+	manual := func() gocv.Mat {
+		return manualBlob(img, blobSize, swapRB.or(true), *crop)
+	}
+
+	a, b := builtin(), manual()
+	diff, err := blobDifference(a, b)
+	a.Close()
+	b.Close()
+	if err != nil {
+		return err
+	}
+	// the two resize slightly differently when cropping
+	fmt.Fprintf(w, "largest difference between the blobs: %.3f\n", diff)
+
+	timings := []struct {
+		name string
+		blob func() gocv.Mat
+		took time.Duration
+	}{
+		{name: "BlobFromImage", blob: builtin},
+		{name: "resize and convert", blob: manual},
+	}
+	for i := range timings {
+		start := time.Now()
+		for n := 0; n < iterations; n++ {
+			blob := timings[i].blob()
+			blob.Close()
+		}
+		timings[i].took = time.Since(start)
+		fmt.Fprintf(w, "%-20v %v per frame\n", timings[i].name, timings[i].took/time.Duration(iterations))
+	}
+
+	faster, slower := timings[0], timings[1]
+	if slower.took < faster.took {
+		faster, slower = slower, faster
+	}
+	fmt.Fprintf(w, "%v is faster by %.0f%%\n", faster.name, 100*(1-float64(faster.took)/float64(slower.took)))
+	return nil
+}
+
+// manualBlob does what BlobFromImage does for one image with no scaling
+// or mean: it resizes img to size, first scaling it to cover size and
+// cutting the middle out of it if crop is set, optionally swaps the red and
+// blue channels, and copies the channels one after another into a
+// 1x3xHxW float blob. The caller must close it.
+func manualBlob(img gocv.Mat, size image.Point, swapRB, crop bool) gocv.Mat {
+	resized := gocv.NewMat()
+	defer resized.Close()
+	if crop {
+		scale := math.Max(float64(size.X)/float64(img.Cols()), float64(size.Y)/float64(img.Rows()))
+		gocv.Resize(img, &resized, image.Point{}, scale, scale, gocv.InterpolationLinear)
+		x := (resized.Cols() - size.X) / 2
+		y := (resized.Rows() - size.Y) / 2
+		middle := resized.Region(image.Rect(x, y, x+size.X, y+size.Y))
+		cropped := middle.Clone()
+		middle.Close()
+		resized.Close()
+		resized = cropped
+	} else {
+		gocv.Resize(img, &resized, size, 0, 0, gocv.InterpolationLinear)
+	}
+	if swapRB {
+		gocv.CvtColor(resized, &resized, gocv.ColorBGRToRGB)
+	}
+	resized.ConvertTo(&resized, gocv.MatTypeCV32F)
+
+	blob := gocv.NewMatWithSizes([]int{1, 3, size.Y, size.X}, gocv.MatTypeCV32F)
+	out, err := blob.DataPtrFloat32()
+	if err != nil {
+		return blob
+	}
+	channels := gocv.Split(resized)
+	plane := size.X * size.Y
+	for i, c := range channels {
+		if data, err := c.DataPtrFloat32(); err == nil {
+			copy(out[i*plane:(i+1)*plane], data)
+		}
+		c.Close()
+	}
+	return blob
+}
+
+// blobDifference returns the largest difference between any two values
+// of blobs a and b.
+func blobDifference(a, b gocv.Mat) (float64, error) {
+	x, err := a.DataPtrFloat32()
+	if err != nil {
+		return 0, err
+	}
+	y, err := b.DataPtrFloat32()
+	if err != nil {
+		return 0, err
+	}
+	if len(x) != len(y) {
+		return 0, fmt.Errorf("blobs differ in size, %d and %d values", len(x), len(y))
+	}
+	var diff float64
+	for i := range x {
+		diff = math.Max(diff, math.Abs(float64(x[i]-y[i])))
+	}
+	return diff, nil
+}
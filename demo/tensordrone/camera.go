@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/opencv"
+	"gocv.io/x/gocv"
+)
+
+var extraCameras = flag.String("cameras", "", "comma separated IDs of more cameras to switch between with the camera action, such as 2,4")
+
+// frameSource is a camera driver that publishes opencv.Frame events.
+type frameSource interface {
+	gobot.Device
+	gobot.Eventer
+}
+
+// parseCameras returns the camera IDs in a -cameras list.
+func parseCameras(spec string) ([]int, error) {
+	var ids []int
+	for _, s := range strings.Split(spec, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("-cameras: %q is not a camera ID", s)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// cameraSwitcher is a camera driver for several cameras, of which only
+// one is open at a time. The gobot camera driver opens its camera for
+// good when it starts, so it cannot be used to switch between them.
+type cameraSwitcher struct {
+	name    string
+	sources []int
+	active  int32
+	next    chan struct{}
+	halt    chan struct{}
+	gobot.Eventer
+}
+
+func newCameraSwitcher(sources []int) *cameraSwitcher {
+	c := &cameraSwitcher{
+		name:    "Camera",
+		sources: sources,
+		next:    make(chan struct{}, 1),
+		halt:    make(chan struct{}),
+		Eventer: gobot.NewEventer(),
+	}
+	c.AddEvent(opencv.Frame)
+	return c
+}
+
+// Name returns the drivers name.
+func (c *cameraSwitcher) Name() string { return c.name }
+
+// SetName sets the drivers name.
+func (c *cameraSwitcher) SetName(n string) { c.name = n }
+
+// Connection returns the drivers connection, which it has none of.
+func (c *cameraSwitcher) Connection() gobot.Connection { return nil }
+
+// Start opens the first camera and publishes its frames, moving on to the
+// next camera whenever switchCamera is called.
+func (c *cameraSwitcher) Start() error {
+	go func() {
+		img := gocv.NewMat()
+		defer img.Close()
+
+		for {
+			i := int(atomic.LoadInt32(&c.active))
+			capture, err := gocv.VideoCaptureDevice(c.sources[i])
+			if err != nil {
+				// gocv returns a capture even when it cannot open one
+				log.Printf("camera %v: %v", c.sources[i], err)
+				capture.Close()
+				capture = nil
+			}
+			if !c.run(capture, &img) {
+				return
+			}
+			atomic.StoreInt32(&c.active, int32((i+1)%len(c.sources)))
+		}
+	}()
+	return nil
+}
+
+// run publishes frames from capture until the camera is switched, when it
+// returns true, or the driver halts. A camera that could not be opened is
+// skipped after a second, and one that fails to read is tried again less
+// and less often, up to once a second, until it reads again.
+func (c *cameraSwitcher) run(capture *gocv.VideoCapture, img *gocv.Mat) bool {
+	if capture == nil {
+		select {
+		case <-time.After(time.Second):
+			return true
+		case <-c.next:
+			return true
+		case <-c.halt:
+			return false
+		}
+	}
+	defer capture.Close()
+
+	var retry time.Duration
+	for {
+		select {
+		case <-c.next:
+			return true
+		case <-c.halt:
+			return false
+		case <-time.After(retry):
+		}
+		if capture.Read(img) {
+			c.Publish(opencv.Frame, *img)
+			retry = 0
+			continue
+		}
+		switch {
+		case retry == 0:
+			retry = 10 * time.Millisecond
+		case retry < time.Second:
+			retry *= 2
+		}
+	}
+}
+
+// Halt stops publishing and closes the open camera.
+func (c *cameraSwitcher) Halt() error {
+	close(c.halt)
+	return nil
+}
+
+// switchCamera closes the camera in use and opens the next one.
+func (c *cameraSwitcher) switchCamera() {
+	select {
+	case c.next <- struct{}{}:
+	default:
+	}
+}
+
+// status describes the camera in use for the overlay.
+func (c *cameraSwitcher) status() string {
+	i := atomic.LoadInt32(&c.active)
+	return fmt.Sprintf("camera: %v (%d of %d)", c.sources[i], i+1, len(c.sources))
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// The channel order and cropping must match how the model was trained.
+// Each model format has its usual channel order: Tensorflow, ONNX and
+// Darknet models expect RGB, so the red and blue channels of the BGR
+// camera frames are swapped for them, while Caffe models such as GoogLeNet
+// and SqueezeNet expect BGR. -swap-rb overrides the format's channel order
+// for a model trained otherwise. Use -crop for models trained on center
+// crops rather than whole, stretched images.
+var (
+	swapRB = autoBoolFlag("swap-rb", "swap the red and blue channels of frames before classifying them, or auto to go by the model format, which is false for Caffe and true otherwise")
+	crop   = flag.Bool("crop", false, "center crop frames to the model input size instead of stretching them")
+	batch  = flag.Int("batch", 1, "average the probabilities of this many recent frames, to steady the classification against motion blur and flicker")
+)
+
+// Frames are shrunk to the blob size for the network anyway, but finding
+// the salient region and scaling a large frame down are quicker on a
+// frame that is already smaller. The window still shows the full frame.
+var inferScale = flag.Float64("infer-scale", 1, "classify a copy of each frame scaled down by this, such as 0.5, for speed, while the window shows it at full size")
+
+// scaleRect scales r by f about the origin, for mapping regions between
+// the inference and display frames.
+func scaleRect(r image.Rectangle, f float64) image.Rectangle {
+	return image.Rect(int(float64(r.Min.X)*f), int(float64(r.Min.Y)*f), int(float64(r.Max.X)*f), int(float64(r.Max.Y)*f))
+}
+
+// blobSize is the size frames are scaled to before they go into the network.
+var blobSize = image.Pt(224, 244)
+
+// maxForwardFailures is how many forward passes in a row may fail before
+// the classifier gives up on the preferred target and switches to the CPU.
+const maxForwardFailures = 3
+
+// classifier runs the network against camera frames.
+type classifier struct {
+	net           gocv.Net
+	input, output string
+	scale         float64
+	mean          gocv.Scalar
+	swapRB        bool
+	descriptions  []string
+
+	// subset is the labels of interest with -label-subset, or nil for
+	// every description
+	subset *labelGroups
+
+	failures int
+	onCPU    bool
+
+	// the scores for the last -batch frames
+	recent [][]float32
+}
+
+// newClassifier opens the model and asks it to run on the
+// requested backend and target, classifying into the labels of interest
+// in -label-subset, if there is one.
+func newClassifier(model string, descriptions []string, backend, target string) (*classifier, error) {
+	subset, err := readLabelSubset(*labelSubset, descriptions)
+	if err != nil {
+		return nil, err
+	}
+	format, err := findModelFormat(*modelFormatFlag, model)
+	if err != nil {
+		return nil, err
+	}
+	if format.config && *modelConfig == "" {
+		return nil, fmt.Errorf("%v models need their network description given with -model-config", format.name)
+	}
+	net, err := readNet(model, *modelConfig, format)
+	if err != nil {
+		return nil, err
+	}
+	c := &classifier{
+		net:          net,
+		descriptions: descriptions,
+		subset:       subset,
+	}
+	c.input, c.output = format.layers()
+	c.scale, c.mean = format.scale, format.mean
+	c.swapRB = swapRB.or(format.swapRB)
+	c.net.SetPreferableBackend(gocv.ParseNetBackend(backend))
+	c.net.SetPreferableTarget(gocv.ParseNetTarget(target))
+	c.onCPU = gocv.ParseNetTarget(target) == gocv.NetTargetCPU
+	return c, nil
+}
+
+// modelAttempts is how many times to try reading the model before giving
+// up, and modelRetryDelay how long to wait before the first retry, which
+// doubles each time.
+const (
+	modelAttempts   = 3
+	modelRetryDelay = 500 * time.Millisecond
+)
+
+// readNet reads the model, and its config file if the format has one,
+// retrying a failed read a couple of
+// times, as models on network or other slow storage sometimes fail to
+// read the first time. A model that is missing or cannot be opened at all
+// is reported straight away, as retrying would not help.
+func readNet(model, config string, format modelFormat) (gocv.Net, error) {
+	delay := modelRetryDelay
+	var last error
+	for attempt := 1; attempt <= modelAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("could not read the model (%v), trying again in %v", last, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		if err := statModel(model, config, format); err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return gocv.Net{}, fmt.Errorf("cannot open model: %v", err)
+			}
+			last = err
+			continue
+		}
+
+		net := format.read(model, config)
+		if !net.Empty() {
+			return net, nil
+		}
+		net.Close()
+		last = fmt.Errorf("%v is not a %v model or could not be read", model, format.name)
+	}
+	return gocv.Net{}, fmt.Errorf("giving up after %d attempts to read the model: %v", modelAttempts, last)
+}
+
+// statModel checks the model file, and the config file if the format has
+// one, can be found.
+func statModel(model, config string, format modelFormat) error {
+	if _, err := os.Stat(model); err != nil {
+		return err
+	}
+	if format.config {
+		if _, err := os.Stat(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the network.
+func (c *classifier) Close() error {
+	return c.net.Close()
+}
+
+// classify returns the most probable description for img and its score.
+func (c *classifier) classify(img gocv.Mat) (string, float32, error) {
+	scores, err := c.scores(img)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// determine the most probable classification, which will be max value
+	best := 0
+	for i, score := range scores {
+		if score > scores[best] {
+			best = i
+		}
+	}
+	return c.label(best), scores[best], nil
+}
+
+// prediction is one possible classification and how likely it is.
+type prediction struct {
+	label string
+	score float32
+}
+
+// predict returns the n most probable descriptions for img, best first.
+func (c *classifier) predict(img gocv.Mat, n int) ([]prediction, error) {
+	scores, err := c.scores(img)
+	if err != nil {
+		return nil, err
+	}
+	return c.rank(scores, n), nil
+}
+
+// predictFrame is predict for img on its own, neither averaged over the
+// last -batch frames nor added to them, for a frame that has already been
+// classified, such as one saved as an example.
+func (c *classifier) predictFrame(img gocv.Mat, n int) ([]prediction, error) {
+	scores, err := c.frameScores(img)
+	if err != nil {
+		return nil, err
+	}
+	if c.subset != nil {
+		scores = c.subset.remap(scores)
+	}
+	return c.rank(scores, n), nil
+}
+
+// rank returns the n highest of scores as predictions, best first.
+func (c *classifier) rank(scores []float32, n int) []prediction {
+	preds := make([]prediction, len(scores))
+	for i, score := range scores {
+		preds[i] = prediction{label: c.label(i), score: score}
+	}
+	sort.SliceStable(preds, func(i, j int) bool {
+		return preds[i].score > preds[j].score
+	})
+	if n < len(preds) {
+		preds = preds[:n]
+	}
+	return preds
+}
+
+// scores returns the score for each description for img, averaged over
+// the last -batch frames, or for each label of interest and other with
+// -label-subset.
+func (c *classifier) scores(img gocv.Mat) ([]float32, error) {
+	scores, err := c.allScores(img)
+	if err != nil || c.subset == nil {
+		return scores, err
+	}
+	return c.subset.remap(scores), nil
+}
+
+// allScores returns the score for each description for img, averaged over
+// the last -batch frames.
+func (c *classifier) allScores(img gocv.Mat) ([]float32, error) {
+	scores, err := c.frameScores(img)
+	if err != nil || *batch <= 1 {
+		return scores, err
+	}
+
+	c.recent = append(c.recent, scores)
+	if len(c.recent) > *batch {
+		c.recent = c.recent[1:]
+	}
+	avg := make([]float32, len(scores))
+	for _, r := range c.recent {
+		for i := range avg {
+			avg[i] += r[i] / float32(len(c.recent))
+		}
+	}
+	return avg, nil
+}
+
+// frameScores returns the score for each description for img alone.
+func (c *classifier) frameScores(img gocv.Mat) ([]float32, error) {
+	probMat, err := c.probabilities(img)
+	if err != nil {
+		return nil, err
+	}
+	defer probMat.Close()
+
+	data, err := probMat.DataPtrFloat32()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("model gave no scores")
+	}
+	scores := make([]float32, len(data))
+	copy(scores, data)
+	if *softmax {
+		softmaxScores(scores)
+	}
+	return scores, nil
+}
+
+// probabilities runs img through the network and returns a single row
+// holding the score for each description. The caller must close it.
+func (c *classifier) probabilities(img gocv.Mat) (gocv.Mat, error) {
+	// convert image Mat to 224x244 blob that the classifier can analyze
+	blob := gocv.BlobFromImage(img, c.scale, blobSize, c.mean, c.swapRB, *crop)
+	defer blob.Close()
+
+	prob, err := c.forward(blob)
+	if err != nil {
+		c.failed(err)
+		return prob, err
+	}
+	defer prob.Close()
+	c.failures = 0
+
+	// reshape the results into a 1x1000 matrix
+	return prob.Reshape(1, 1), nil
+}
+
+// check runs one blank frame through the network, so that a model which
+// does not take the blob size fails at startup instead of classifying
+// every frame wrongly.
+func (c *classifier) check() error {
+	blank := gocv.NewMatWithSize(blobSize.Y, blobSize.X, gocv.MatTypeCV8UC3)
+	defer blank.Close()
+
+	probMat, err := c.probabilities(blank)
+	if err != nil {
+		return fmt.Errorf("model rejected a %dx%d input blob, it may expect a different input size: %v", blobSize.X, blobSize.Y, err)
+	}
+	defer probMat.Close()
+
+	// models may pad their output with unused classes, so only fewer
+	// scores than descriptions suggests the wrong descriptions file
+	if n := probMat.Cols(); n < len(c.descriptions) {
+		log.Printf("model gives %d scores but there are %d descriptions, check the descriptions file matches the model", n, len(c.descriptions))
+	}
+	return nil
+}
+
+// label returns the description at position i in the descriptions file,
+// or the label of interest at position i with -label-subset.
+func (c *classifier) label(i int) string {
+	if c.subset != nil {
+		return c.subset.label(i)
+	}
+	if i < 0 || i >= len(c.descriptions) {
+		return "Unknown"
+	}
+	return c.descriptions[i]
+}
+
+// forward feeds the blob into the network and runs a forward pass,
+// turning an empty result into an error. An OpenCV exception in the pass
+// is not a Go panic and cannot be recovered from here: it ends the
+// program.
+func (c *classifier) forward(blob gocv.Mat) (gocv.Mat, error) {
+	// feed the blob into the classifier network
+	c.net.SetInput(blob, c.input)
+
+	// run a forward pass thru the network
+	prob := c.net.Forward(c.output)
+	if prob.Empty() {
+		prob.Close()
+		return prob, errors.New("forward pass returned no output")
+	}
+	return prob, nil
+}
+
+// failed records a failed forward pass, and once too many have failed in
+// a row moves the network over to the CPU.
+func (c *classifier) failed(err error) {
+	c.failures++
+	if c.onCPU || c.failures < maxForwardFailures {
+		return
+	}
+
+	log.Printf("%v, falling back to CPU inference", err)
+	c.net.SetPreferableBackend(gocv.NetBackendDefault)
+	c.net.SetPreferableTarget(gocv.NetTargetCPU)
+	c.onCPU = true
+	c.failures = 0
+}
+
+// autoBool is a boolean flag that can also be left as auto, for a default
+// that depends on other flags.
+type autoBool struct {
+	set, value bool
+}
+
+func autoBoolFlag(name, usage string) *autoBool {
+	b := &autoBool{}
+	flag.Var(b, name, usage)
+	return b
+}
+
+func (b *autoBool) String() string {
+	if !b.set {
+		return "auto"
+	}
+	return strconv.FormatBool(b.value)
+}
+
+func (b *autoBool) Set(s string) error {
+	if s == "auto" {
+		b.set = false
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.set, b.value = true, v
+	return nil
+}
+
+// IsBoolFlag lets the flag be given on its own for true.
+func (b *autoBool) IsBoolFlag() bool { return true }
+
+// or returns the flag's value, or auto if it was left as auto.
+func (b *autoBool) or(auto bool) bool {
+	if !b.set {
+		return auto
+	}
+	return b.value
+}
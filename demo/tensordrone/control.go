@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	hoverSettle = flag.Bool("hover-settle", false, "briefly climb when the throttle stick is released, for drones that sink at zero throttle")
+	settlePower = flag.Int("settle-power", 10, "throttle percent used to settle into a hover")
+	settleTime  = flag.Duration("settle-time", 300*time.Millisecond, "how long to apply the settle throttle for")
+)
+
+var (
+	minAltitude = flag.Float64("min-altitude", 0, "do not descend below this estimated altitude in meters, 0 for no floor")
+	maxAltitude = flag.Float64("max-altitude", 0, "do not climb above this estimated altitude in meters, 0 for no ceiling")
+)
+
+var (
+	guardAltitude = flag.Float64("guard-altitude", 0, "below this estimated altitude in meters, gently limit forward, backward and sideways commands, 0 for off")
+	guardMin      = flag.Float64("guard-min", 0.3, "fraction of full horizontal command allowed on the ground when -guard-altitude is set")
+)
+
+// guardFactor is the current prop guard scaling, for the overlay.
+var guardFactor atomic.Value
+
+// altitudeLimit is the altitude limit, if any, that stopped the last
+// throttle command, for the overlay.
+var altitudeLimit atomic.Value
+
+var deadzonePercent = flag.Float64("deadzone", 10, "how far, in percent, a stick must move from center before it sends a command")
+
+// deadzone is the current -deadzone, which can be changed from the menu.
+var deadzone atomic.Value
+
+// The minidrone itself can limit its tilt and vertical speed, but the gobot
+// driver has no commands for those settings, so the limits are applied
+// here to the commands instead.
+var maxCommand = flag.Int("max-command", 100, "beginner mode: cap every pitch, roll, yaw and throttle command at this percent")
+
+// The drone reports no rate of turn, so there is no measuring the yaw rate
+// to hold it under the limit. Instead the limit is turned into a cap on the
+// yaw command using the turn rate at full yaw from -yaw-rate.
+var maxYawRate = flag.Float64("max-yaw-rate", 0, "cap yaw commands so that the drone turns no faster than this many degrees per second, to keep the video watchable, 0 for no cap")
+
+// yawCap returns the most yaw command percent that keeps the turn rate
+// under max degrees per second, for a drone turning at full degrees per
+// second at full yaw. A max of zero is no cap.
+func yawCap(max, full float64) int {
+	if max <= 0 || full <= 0 || max >= full {
+		return 100
+	}
+	return int(max / full * 100)
+}
+
+// capYaw caps a yaw command to -max-yaw-rate.
+func capYaw(cmd int) int {
+	if limit := yawCap(*maxYawRate, *yawRate); cmd > limit {
+		return limit
+	}
+	return cmd
+}
+
+// l2, r2 are the positions of the analog triggers, which rest at minus the
+// controls offset and read the offset when fully pressed.
+var l2, r2 atomic.Value
+
+// command turns a stick position into a drone command, shaped by the
+// preset in use, compensated for the battery and scaled by the triggers.
+func command(val float64) int {
+	p := shaping()
+	precision := triggerPosition(l2.Load().(float64))
+	boost := triggerPosition(r2.Load().(float64))
+	cmd := batteryCompensated(stickPercent(applyExpo(val, p.expo), deadzone.Load().(float64)))
+	return scaleCommand(cmd, p.limit, precision, boost)
+}
+
+// stickPercent turns a stick position into a percentage of full stick,
+// like minidrone.ValidatePitch but with the deadzone as a percentage.
+func stickPercent(val, deadzone float64) int {
+	value := math.Abs(val) / controls.Offset
+	switch {
+	case value*100 < deadzone:
+		return 0
+	case value > 1:
+		return 100
+	}
+	return int(value * 100)
+}
+
+// scaleCommand scales cmd by how far the precision and boost triggers are
+// pressed, from 0 to 1. Precision brings commands down to a quarter, for
+// fine positioning, and boost up to double, raising the limit with it.
+func scaleCommand(cmd, limit int, precision, boost float64) int {
+	gain := (1 - 0.75*precision) * (1 + boost)
+	scaled := int(float64(cmd) * gain)
+
+	limit = int(float64(limit) * (1 + boost))
+	if limit > 100 {
+		limit = 100
+	}
+	if scaled > limit {
+		scaled = limit
+	}
+	return scaled
+}
+
+// triggerPosition turns an analog trigger axis value into how far it is
+// pressed, from 0 to 1.
+func triggerPosition(val float64) float64 {
+	p := (val + controls.Offset) / (2 * controls.Offset)
+	switch {
+	case p < 0:
+		return 0
+	case p > 1:
+		return 1
+	}
+	return p
+}
+
+// powerMode describes the preset in use for the overlay.
+func powerMode() string {
+	p := shaping()
+	if p.limit < 100 {
+		return fmt.Sprintf("mode: %v (%d%%)", p.name, p.limit)
+	}
+	return "mode: " + p.name
+}
+
+// throttleSettle turns the release of the throttle stick into a short burst
+// of climb, so that drones which sink at zero throttle settle into a hover.
+// The minidrone does not report its altitude, so the burst is timed rather
+// than stopping at a measured height.
+type throttleSettle struct {
+	power    int
+	duration time.Duration
+
+	held  bool
+	until time.Time
+}
+
+// moved records that the throttle stick is out of its deadzone.
+func (s *throttleSettle) moved() {
+	s.held = true
+}
+
+// idle returns the throttle to send while the stick is centered: the
+// settle power for a short time after it was released, and zero after that.
+func (s *throttleSettle) idle(now time.Time) int {
+	if s.held {
+		s.held = false
+		s.until = now.Add(s.duration)
+	}
+	if now.Before(s.until) {
+		return s.power
+	}
+	return 0
+}
+
+// altitudeEnvelope returns the throttle to send, positive for up, given
+// the current altitude. Climbs are dropped at or above max and descents at
+// or below min, in which case the limit that was hit is also returned. A
+// limit of zero is not enforced.
+func altitudeEnvelope(climb int, altitude, min, max float64) (int, string) {
+	switch {
+	case climb > 0 && max > 0 && altitude >= max:
+		return 0, "altitude ceiling"
+	case climb < 0 && min > 0 && altitude <= min:
+		return 0, "altitude floor"
+	}
+	return climb, ""
+}
+
+// propGuard returns the factor that horizontal commands are scaled by at
+// the given altitude, so that the drone translates gently near the ground
+// where the props could catch it. It is min on the ground and rises in a
+// straight line to 1 at guard. A guard of zero turns it off.
+func propGuard(altitude, guard, min float64) float64 {
+	if guard <= 0 || altitude >= guard {
+		return 1
+	}
+	if altitude < 0 {
+		altitude = 0
+	}
+	return min + (1-min)*altitude/guard
+}
+
+// guarded scales a horizontal command by the prop guard factor.
+func guarded(cmd int, factor float64) int {
+	return int(float64(cmd) * factor)
+}
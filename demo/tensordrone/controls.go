@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+var controlsFile = flag.String("controls", "", "JSON file of stick thresholds and button actions, instead of or on top of the \"controls\" in the joystick file")
+
+// ControlConfig is how the sticks and buttons are read, for tuning to a
+// controller or drone without recompiling. It is read from the "controls"
+// object of the joystick mapping file, which gobot ignores, and then from
+// -controls, each leaving whatever it does not give as it was.
+//
+// The deadzones are raw axis values, out of Offset, that a stick must pass
+// before it counts as moved, both to send a command and to take over from
+// the orbit, lock and the rest. -deadzone applies on top of them, to the
+// command sent. Offset is the axis value of a stick pushed all the way, at
+// which commands reach full power. Actions map an action to the joystick
+// event that triggers it, such as "square_press", and -bindings applies
+// on top of them.
+type ControlConfig struct {
+	Offset    float64 `json:"offset"`
+	Deadzones struct {
+		LeftX  float64 `json:"left_x"`
+		LeftY  float64 `json:"left_y"`
+		RightX float64 `json:"right_x"`
+		RightY float64 `json:"right_y"`
+	} `json:"deadzones"`
+	Actions map[string]string `json:"actions"`
+}
+
+// controls is the ControlConfig in use. It is set before the drone is
+// flown and never changed after.
+var controls = defaultControls()
+
+// defaultControls returns the controls used when nothing is configured,
+// with the yaw axis needing twice the travel of the others, so that
+// climbing does not turn the drone.
+func defaultControls() ControlConfig {
+	var c ControlConfig
+	// the most an SDL joystick axis reads
+	c.Offset = 32767
+	c.Deadzones.LeftX = 20
+	c.Deadzones.LeftY = 10
+	c.Deadzones.RightX = 10
+	c.Deadzones.RightY = 10
+	return c
+}
+
+// readControls returns the default controls updated from the "controls" in
+// the joystick mapping file, if there is one, and then from the file at
+// path, if it is given, checking that the result can be flown with.
+func readControls(joystickFile, path string) (ControlConfig, error) {
+	c := defaultControls()
+	if joystickFile != "" {
+		b, err := ioutil.ReadFile(joystickFile)
+		if err != nil {
+			return c, err
+		}
+		var mapping struct {
+			Controls *ControlConfig `json:"controls"`
+		}
+		mapping.Controls = &c
+		if err := json.Unmarshal(b, &mapping); err != nil {
+			return c, fmt.Errorf("controls in joystick file %v: %v", joystickFile, err)
+		}
+	}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return c, fmt.Errorf("controls file %v: %v", path, err)
+		}
+	}
+	if err := c.validate(); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// validate checks that the offset and deadzones leave the sticks working,
+// and that the actions are known ones.
+func (c ControlConfig) validate() error {
+	if c.Offset <= 0 {
+		return fmt.Errorf("controls offset %v must be more than 0", c.Offset)
+	}
+	deadzones := []struct {
+		axis  string
+		value float64
+	}{
+		{"left_x", c.Deadzones.LeftX},
+		{"left_y", c.Deadzones.LeftY},
+		{"right_x", c.Deadzones.RightX},
+		{"right_y", c.Deadzones.RightY},
+	}
+	for _, d := range deadzones {
+		if d.value < 0 || d.value >= c.Offset {
+			return fmt.Errorf("controls deadzone %v for %v must be at least 0 and less than the offset %v", d.value, d.axis, c.Offset)
+		}
+	}
+
+	actions := make([]string, 0, len(c.Actions))
+	for action := range c.Actions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		if _, ok := defaultBindings[action]; !ok {
+			return fmt.Errorf("controls has unknown action %q, expected one of %v", action, strings.Join(actionNames(), ", "))
+		}
+		switch event := c.Actions[action]; {
+		case event == "":
+			return fmt.Errorf("controls has no event for action %q", action)
+		case strings.HasSuffix(strings.ToLower(event), "_release"):
+			return fmt.Errorf("controls event %q for action %q must be a button press", event, action)
+		}
+	}
+	return nil
+}
+
+// bindings returns the default bindings of action to button with the
+// actions in c applied.
+func (c ControlConfig) bindings() map[string]string {
+	bindings := make(map[string]string, len(defaultBindings))
+	for action, button := range defaultBindings {
+		bindings[action] = button
+	}
+	for action, event := range c.Actions {
+		bindings[action] = strings.TrimSuffix(strings.ToLower(event), "_press")
+	}
+	return bindings
+}
+
+// leftMoved and rightMoved report whether a stick is out of its deadzones.
+func (c ControlConfig) leftMoved(s pair) bool {
+	return s.x > c.Deadzones.LeftX || s.x < -c.Deadzones.LeftX || s.y > c.Deadzones.LeftY || s.y < -c.Deadzones.LeftY
+}
+
+func (c ControlConfig) rightMoved(s pair) bool {
+	return s.x > c.Deadzones.RightX || s.x < -c.Deadzones.RightX || s.y > c.Deadzones.RightY || s.y < -c.Deadzones.RightY
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadControls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "controls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		if content == "" {
+			return ""
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	mapping := `{"name": "pad", "axis": [{"name": "left_x", "id": 0}], "buttons": []`
+
+	tests := []struct {
+		name     string
+		joystick string
+		controls string
+		// offset and deadzones are left_x, left_y, right_x and right_y
+		offset    float64
+		deadzones [4]float64
+		bindings  map[string]string
+		err       string
+	}{
+		{
+			name:      "defaults",
+			offset:    32767,
+			deadzones: [4]float64{20, 10, 10, 10},
+		},
+		{
+			name:      "mapping without controls",
+			joystick:  mapping + `}`,
+			offset:    32767,
+			deadzones: [4]float64{20, 10, 10, 10},
+		},
+		{
+			name:      "some from the mapping",
+			joystick:  mapping + `, "controls": {"deadzones": {"left_x": 500}}}`,
+			offset:    32767,
+			deadzones: [4]float64{500, 10, 10, 10},
+		},
+		{
+			name:      "controls file on top of the mapping",
+			joystick:  mapping + `, "controls": {"offset": 1000, "deadzones": {"left_x": 500, "right_y": 50}}}`,
+			controls:  `{"deadzones": {"left_x": 100}}`,
+			offset:    1000,
+			deadzones: [4]float64{100, 10, 10, 50},
+		},
+		{
+			name:      "actions",
+			controls:  `{"actions": {"stop": "cross_press", "takeoff": "Triangle"}}`,
+			offset:    32767,
+			deadzones: [4]float64{20, 10, 10, 10},
+			bindings:  map[string]string{"stop": "cross", "takeoff": "triangle", "land": "x"},
+		},
+		{
+			name:      "zero deadzone",
+			controls:  `{"deadzones": {"left_y": 0}}`,
+			offset:    32767,
+			deadzones: [4]float64{20, 0, 10, 10},
+		},
+		{
+			name:     "zero offset",
+			controls: `{"offset": 0}`,
+			err:      "offset 0 must be more than 0",
+		},
+		{
+			name:     "negative deadzone",
+			controls: `{"deadzones": {"right_x": -1}}`,
+			err:      "deadzone -1 for right_x",
+		},
+		{
+			name:     "deadzone as big as the offset",
+			joystick: mapping + `, "controls": {"offset": 100, "deadzones": {"left_x": 10}}}`,
+			controls: `{"deadzones": {"left_x": 100}}`,
+			err:      "deadzone 100 for left_x",
+		},
+		{
+			name:     "unknown action",
+			controls: `{"actions": {"flip": "circle_press"}}`,
+			err:      `unknown action "flip"`,
+		},
+		{
+			name:     "empty event",
+			controls: `{"actions": {"stop": ""}}`,
+			err:      `no event for action "stop"`,
+		},
+		{
+			name:     "release event",
+			controls: `{"actions": {"stop": "square_release"}}`,
+			err:      "must be a button press",
+		},
+		{
+			name:     "broken json",
+			controls: `{"offset": `,
+			err:      "controls file",
+		},
+		{
+			name:     "wrong type in the mapping",
+			joystick: mapping + `, "controls": {"offset": "full"}}`,
+			err:      "controls in joystick file",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := readControls(write("joystick.json", test.joystick), write("controls.json", test.controls))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Offset != test.offset {
+				t.Errorf("offset %v, want %v", c.Offset, test.offset)
+			}
+			got := [4]float64{c.Deadzones.LeftX, c.Deadzones.LeftY, c.Deadzones.RightX, c.Deadzones.RightY}
+			if got != test.deadzones {
+				t.Errorf("deadzones %v, want %v", got, test.deadzones)
+			}
+			bindings := c.bindings()
+			for action, button := range test.bindings {
+				if bindings[action] != button {
+					t.Errorf("%v bound to %q, want %q", action, bindings[action], button)
+				}
+			}
+		})
+	}
+}
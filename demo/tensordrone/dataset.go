@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var datasetDir = flag.String("dataset-dir", "dataset", "directory that frames saved with the good and bad buttons are written to")
+
+// exampleRequests carries the kind of example, "good" or "bad", that the
+// next frame should be saved as.
+var exampleRequests = make(chan string, 1)
+
+// requestExample asks for the next frame to be saved as kind. A request
+// made while one is still waiting is dropped.
+func requestExample(kind string) {
+	select {
+	case exampleRequests <- kind:
+	default:
+	}
+}
+
+// droneState is what the drone was doing when a frame was saved.
+type droneState struct {
+	// Battery is the battery percent, or -1 if it is not known yet.
+	Battery  int     `json:"battery"`
+	Altitude float64 `json:"estimated_altitude"`
+	Phase    string  `json:"phase"`
+	Mode     string  `json:"mode"`
+}
+
+// exampleMetadata is written as a JSON sidecar next to each saved frame.
+type exampleMetadata struct {
+	Time        time.Time          `json:"time"`
+	Kind        string             `json:"kind"`
+	Predictions []predictionRecord `json:"predictions"`
+	Drone       droneState         `json:"drone"`
+	Tuning      map[string]string  `json:"tuning"`
+}
+
+// predictionRecord is a prediction as written to the sidecar.
+type predictionRecord struct {
+	Label string  `json:"label"`
+	Score float32 `json:"score"`
+}
+
+// saveExample writes img to dir/kind as a JPEG, along with a text file of
+// the predictions the classifier made for it, so that the frames can be
+// reviewed and used to retrain the model. A JSON sidecar also records the
+// predictions, the state of the drone and the tuning flags in use.
+func saveExample(dir, kind string, img gocv.Mat, preds []prediction, state droneState) error {
+	folder := filepath.Join(dir, kind)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := filepath.Join(folder, now.Format("20060102-150405.000"))
+	if !gocv.IMWrite(name+".jpg", img) {
+		return fmt.Errorf("cannot write %v.jpg", name)
+	}
+
+	f, err := os.Create(name + ".txt")
+	if err != nil {
+		return err
+	}
+	for _, p := range preds {
+		fmt.Fprintf(f, "%v\t%v\n", p.label, p.score)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	meta := exampleMetadata{Time: now, Kind: kind, Drone: state, Tuning: make(map[string]string)}
+	for _, p := range preds {
+		meta.Predictions = append(meta.Predictions, predictionRecord{Label: p.label, Score: p.score})
+	}
+	for _, tuning := range tuningFlags {
+		meta.Tuning[tuning] = flag.Lookup(tuning).Value.String()
+	}
+	b, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name+".json", append(b, '\n'), 0644)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var demoScriptFile = flag.String("demo-script", "", "fly an unattended demo from a script of timed commands, stopping for good at any controller input")
+
+// scriptMoves are the movement commands a demo script can hold for a
+// while, each taking a percent and a duration.
+var scriptMoves = map[string]bool{
+	"forward": true, "backward": true, "left": true, "right": true,
+	"up": true, "down": true, "clockwise": true, "counterclockwise": true,
+}
+
+// scriptCommands are the commands a demo script can give that take nothing
+// after them.
+var scriptCommands = map[string]bool{
+	"trim": true, "takeoff": true, "land": true, "hover": true, "repeat": true,
+}
+
+// scriptStep is one command in a demo script.
+type scriptStep struct {
+	cmd   string
+	value int
+	hold  time.Duration
+}
+
+// readDemoScript reads a demo script from a file. See parseDemoScript.
+func readDemoScript(path string) ([]scriptStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseDemoScript(f)
+}
+
+// parseDemoScript reads a demo script, which has one command per line:
+//
+//	trim
+//	wait 2s
+//	takeoff
+//	wait 3s
+//	forward 20 1s
+//	right 20 1s
+//	backward 20 1s
+//	left 20 1s
+//	land
+//	wait 10s
+//	repeat
+//
+// A movement command (forward, backward, left, right, up, down, clockwise
+// or counterclockwise) is held at the percent given for the duration given,
+// and then stopped, and is ignored unless the drone is flying. hover stops
+// all movement, trim calibrates the drone on the ground and repeat starts
+// the script again from the top, which must take some time before it so as
+// not to send commands as fast as it can. Blank lines and lines starting
+// with # are ignored.
+func parseDemoScript(r io.Reader) ([]scriptStep, error) {
+	var steps []scriptStep
+	var held time.Duration
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(strings.ToLower(text))
+		step := scriptStep{cmd: fields[0]}
+		var err error
+		switch {
+		case scriptMoves[step.cmd] && len(fields) == 3:
+			step.value, err = strconv.Atoi(fields[1])
+			if err == nil && (step.value < 0 || step.value > 100) {
+				err = fmt.Errorf("%v must be between 0 and 100", step.value)
+			}
+			if err == nil {
+				step.hold, err = time.ParseDuration(fields[2])
+			}
+		case step.cmd == "wait" && len(fields) == 2:
+			step.hold, err = time.ParseDuration(fields[1])
+		case scriptCommands[step.cmd] && len(fields) == 1:
+		default:
+			err = fmt.Errorf("cannot understand %q", text)
+		}
+		if err == nil && step.cmd == "repeat" && held == 0 {
+			err = fmt.Errorf("repeat would loop without waiting, hold or wait for something before it")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		held += step.hold
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+// scriptRunner flies a demo script until it ends or is aborted.
+type scriptRunner struct {
+	steps   []scriptStep
+	running int32
+	abortMu sync.Once
+	aborted chan struct{}
+}
+
+func newScriptRunner(steps []scriptStep) *scriptRunner {
+	return &scriptRunner{steps: steps, aborted: make(chan struct{})}
+}
+
+// isRunning reports whether the script is flying the drone. It is nil safe,
+// for when there is no script.
+func (s *scriptRunner) isRunning() bool {
+	return s != nil && atomic.LoadInt32(&s.running) == 1
+}
+
+// abort stops the script for good, leaving the drone to the pilot.
+func (s *scriptRunner) abort(why string) {
+	if !s.isRunning() {
+		return
+	}
+	s.abortMu.Do(func() {
+		atomic.StoreInt32(&s.running, 0)
+		close(s.aborted)
+		log.Println("demo script stopped:", why)
+	})
+}
+
+// start runs the script in the background, calling do for each command.
+// A movement command is followed by the same command with a value of zero
+// once it has been held for long enough.
+func (s *scriptRunner) start(do func(step scriptStep)) {
+	atomic.StoreInt32(&s.running, 1)
+	go func() {
+		defer atomic.StoreInt32(&s.running, 0)
+		for {
+			for _, step := range s.steps {
+				if step.cmd == "repeat" {
+					break
+				}
+				// an abort while a step was held may have come just
+				// as its time was up
+				if !s.isRunning() {
+					return
+				}
+				if step.cmd != "wait" {
+					do(step)
+				}
+				select {
+				case <-time.After(step.hold):
+				case <-s.aborted:
+					return
+				}
+				if scriptMoves[step.cmd] {
+					do(scriptStep{cmd: step.cmd})
+				}
+			}
+			if len(s.steps) == 0 || s.steps[len(s.steps)-1].cmd != "repeat" {
+				log.Println("demo script finished")
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var cameraFPS = flag.Float64("camera-fps", 0, "frame rate the camera delivers at, such as 30, to warn when frames arrive later than it and the pipeline cannot keep up (0 for off)")
+
+const (
+	// driftWindow is how far back frame arrivals are looked at, so that a
+	// pause such as a model reload is forgotten once it has passed.
+	driftWindow = 5 * time.Second
+	// driftLimit is how far behind the frames in driftWindow may fall
+	// before there is a warning.
+	driftLimit = 500 * time.Millisecond
+)
+
+// driftChecker timestamps frames as they arrive and compares them against
+// the times they should have arrived at -camera-fps. A camera delivers at
+// its own rate whatever happens downstream, so when frames arrive late,
+// the processing is taking too long and the hardware is the bottleneck.
+// It is nil without -camera-fps, and then never warns.
+type driftChecker struct {
+	interval time.Duration
+
+	sync.Mutex
+	arrivals []time.Time
+	behind   time.Duration
+}
+
+func newDriftChecker(fps float64) *driftChecker {
+	if fps <= 0 {
+		return nil
+	}
+	return &driftChecker{interval: time.Duration(float64(time.Second) / fps)}
+}
+
+// frame records a frame arriving at now.
+func (d *driftChecker) frame(now time.Time) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	d.arrivals = append(d.arrivals, now)
+	i := 0
+	for i < len(d.arrivals)-1 && now.Sub(d.arrivals[i]) > driftWindow {
+		i++
+	}
+	d.arrivals = d.arrivals[i:]
+
+	// how much longer the frames in the window took to arrive than the
+	// camera takes to deliver them
+	n := len(d.arrivals)
+	took := d.arrivals[n-1].Sub(d.arrivals[0])
+	expected := time.Duration(n-1) * d.interval
+	behind := took - expected
+
+	switch {
+	case behind > driftLimit && d.behind <= driftLimit:
+		log.Printf("frames are arriving %v behind -camera-fps over the last %v, the pipeline cannot keep up", behind.Round(time.Millisecond), driftWindow)
+	case behind <= driftLimit && d.behind > driftLimit:
+		log.Println("frames are arriving on time again")
+	}
+	d.behind = behind
+}
+
+// status warns that frames are arriving late, for the overlay, or returns
+// "" if they are not.
+func (d *driftChecker) status() string {
+	if d == nil {
+		return ""
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	if d.behind <= driftLimit {
+		return ""
+	}
+	return fmt.Sprintf("warning: frames %v behind the camera", d.behind.Round(100*time.Millisecond))
+}
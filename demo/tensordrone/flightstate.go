@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FlightState is the phase of flight the drone is in.
+type FlightState int
+
+const (
+	// Disarmed is on the ground and refusing to take off.
+	Disarmed FlightState = iota
+	// Armed is on the ground and ready to take off.
+	Armed
+	// TakingOff has been told to take off and is climbing.
+	TakingOff
+	// Flying is in the air and accepting movement commands.
+	Flying
+	// Landing has been told to land and is descending.
+	Landing
+	// Emergency has cut the motors and must be disarmed before flying again.
+	Emergency
+)
+
+func (s FlightState) String() string {
+	switch s {
+	case Disarmed:
+		return "disarmed"
+	case Armed:
+		return "armed"
+	case TakingOff:
+		return "taking off"
+	case Flying:
+		return "flying"
+	case Landing:
+		return "landing"
+	case Emergency:
+		return "emergency"
+	}
+	return fmt.Sprintf("FlightState(%d)", int(s))
+}
+
+// transitions lists the states each state may move to.
+var transitions = map[FlightState][]FlightState{
+	Disarmed:  {Armed, Emergency},
+	Armed:     {Disarmed, TakingOff, Emergency},
+	TakingOff: {Flying, Landing, Disarmed, Emergency},
+	Flying:    {Landing, Disarmed, Emergency},
+	Landing:   {Flying, Disarmed, Emergency},
+	Emergency: {Disarmed},
+}
+
+// flightPhase holds the current FlightState and guards changes to it.
+type flightPhase struct {
+	sync.Mutex
+	state FlightState
+}
+
+// current returns the state the drone is in.
+func (f *flightPhase) current() FlightState {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.state
+}
+
+// is reports whether the drone is in state s.
+func (f *flightPhase) is(s FlightState) bool {
+	return f.current() == s
+}
+
+// to moves to state s, returning an error if that is not allowed from the
+// current state. Moving to the current state is always allowed.
+func (f *flightPhase) to(s FlightState) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.state == s {
+		return nil
+	}
+	for _, next := range transitions[f.state] {
+		if next == s {
+			f.state = s
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot go from %v to %v", f.state, s)
+}
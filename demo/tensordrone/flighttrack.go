@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	trackPath = flag.String("track", "", "write the estimated flight path to this file when the demo exits, as GPX if it ends in .gpx and CSV otherwise")
+	flySpeed  = flag.Float64("fly-speed", 2, "horizontal speed of the drone in m/s at full pitch or roll, used to estimate the flight path")
+	yawRate   = flag.Float64("yaw-rate", 180, "turn rate of the drone in degrees per second at full yaw, used to estimate the flight path")
+)
+
+// metersPerDegree is roughly how far one degree of latitude is, for
+// placing the estimated path on a map in GPX files.
+const metersPerDegree = 111320.0
+
+// trackPoint is one point on the estimated flight path, in meters from
+// where the drone took off, with x to its right and y ahead of it.
+type trackPoint struct {
+	at      time.Time
+	x, y, z float64
+	heading float64
+}
+
+// flightTrack estimates where the drone has flown. Like the altimeter, it
+// is dead reckoning from the commands sent, as the minidrone reports no
+// position or motion, so it drifts badly and only gives the rough shape
+// of a flight. It is nil when neither -track nor -fence is set.
+type flightTrack struct {
+	speed, turn float64
+
+	sync.Mutex
+	points  []trackPoint
+	current trackPoint
+}
+
+func newFlightTrack(path string, speed, turn float64) *flightTrack {
+	if path == "" && *fenceRadius <= 0 {
+		return nil
+	}
+	return &flightTrack{speed: speed, turn: turn}
+}
+
+// update moves the estimate on to now, given the commands in use since
+// the last update and the estimated altitude.
+func (t *flightTrack) update(commands [4]int, altitude float64, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	p := t.current
+	if !p.at.IsZero() {
+		dt := now.Sub(p.at).Seconds()
+		p.heading += float64(commands[yawAxis]) / 100 * t.turn * dt
+		p.heading = math.Mod(p.heading+360, 360)
+
+		rad := p.heading * math.Pi / 180
+		ahead := float64(commands[pitchAxis]) / 100 * t.speed * dt
+		right := float64(commands[rollAxis]) / 100 * t.speed * dt
+		p.x += ahead*math.Sin(rad) + right*math.Cos(rad)
+		p.y += ahead*math.Cos(rad) - right*math.Sin(rad)
+	}
+	p.at, p.z = now, altitude
+	t.current = p
+	t.points = append(t.points, p)
+}
+
+// position returns the estimated position and heading.
+func (t *flightTrack) position() (x, y, heading float64) {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.current.x, t.current.y, t.current.heading
+}
+
+// pause stops the estimate moving on, such as while the drone is on the
+// ground, so that the time spent there does not count as flying.
+func (t *flightTrack) pause() {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	t.current.at = time.Time{}
+}
+
+// write writes the path to path, as GPX if it ends in .gpx and as CSV of
+// time, x, y, altitude and heading otherwise, unless path is empty.
+func (t *flightTrack) write(path string) error {
+	if t == nil || path == "" {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if strings.EqualFold(filepath.Ext(path), ".gpx") {
+		writeGPX(w, t.points)
+	} else {
+		fmt.Fprintln(w, "unix_ms,x,y,estimated_altitude,heading")
+		for _, p := range t.points {
+			fmt.Fprintf(w, "%d,%.2f,%.2f,%.2f,%.0f\n", p.at.UnixNano()/int64(time.Millisecond), p.x, p.y, p.z, p.heading)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeGPX writes points as a GPX track. There is no GPS, so the takeoff
+// point is put at latitude and longitude 0, where a degree is the same
+// distance both ways.
+func writeGPX(w *bufio.Writer, points []trackPoint) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<gpx version="1.1" creator="tensordrone" xmlns="http://www.topografix.com/GPX/1/1">`)
+	fmt.Fprintln(w, `<trk><name>estimated flight path</name><trkseg>`)
+	for _, p := range points {
+		fmt.Fprintf(w, `<trkpt lat="%.8f" lon="%.8f"><ele>%.2f</ele><time>%v</time></trkpt>`+"\n",
+			p.y/metersPerDegree, p.x/metersPerDegree, p.z, p.at.UTC().Format(time.RFC3339Nano))
+	}
+	fmt.Fprintln(w, `</trkseg></trk>`)
+	fmt.Fprintln(w, `</gpx>`)
+}
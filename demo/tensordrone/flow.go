@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+var opticalFlow = flag.Bool("flow", false, "draw the optical flow between frames as motion vectors over the video")
+
+const (
+	// flowScale is how much frames are shrunk before working out the flow,
+	// which is slow at full size.
+	flowScale = 0.5
+	// flowStep is the spacing in pixels of the drawn vectors.
+	flowStep = 16
+	// flowMin is the shortest movement in pixels that is drawn.
+	flowMin = 1.0
+)
+
+// flowProcessor is a FrameProcessor that works out the dense optical flow
+// between each frame and the one before it, and draws it as arrows.
+type flowProcessor struct {
+	small gocv.Mat
+	gray  gocv.Mat
+	prev  gocv.Mat
+	flow  gocv.Mat
+}
+
+func newFlowProcessor() *flowProcessor {
+	return &flowProcessor{
+		small: gocv.NewMat(),
+		gray:  gocv.NewMat(),
+		prev:  gocv.NewMat(),
+		flow:  gocv.NewMat(),
+	}
+}
+
+// Close releases the frames kept between calls.
+func (f *flowProcessor) Close() error {
+	f.flow.Close()
+	f.prev.Close()
+	f.gray.Close()
+	return f.small.Close()
+}
+
+// Process implements FrameProcessor.
+func (f *flowProcessor) Process(img gocv.Mat, result ClassificationResult) {
+	gocv.Resize(img, &f.small, image.Point{}, flowScale, flowScale, gocv.InterpolationLinear)
+	gocv.CvtColor(f.small, &f.gray, gocv.ColorBGRToGray)
+
+	// the first frame, or one of a new size, has nothing to compare with
+	if f.prev.Empty() || f.prev.Cols() != f.gray.Cols() || f.prev.Rows() != f.gray.Rows() {
+		f.gray.CopyTo(&f.prev)
+		return
+	}
+
+	gocv.CalcOpticalFlowFarneback(f.prev, f.gray, &f.flow, 0.5, 3, 15, 3, 5, 1.2, 0)
+	f.gray.CopyTo(&f.prev)
+
+	c := color.RGBA{255, 128, 0, 0}
+	step := int(flowStep * flowScale)
+	for y := step / 2; y < f.flow.Rows(); y += step {
+		for x := step / 2; x < f.flow.Cols(); x += step {
+			v := f.flow.GetVecfAt(y, x)
+			if v[0]*v[0]+v[1]*v[1] < flowMin*flowMin {
+				continue
+			}
+			from := image.Pt(int(float64(x)/flowScale), int(float64(y)/flowScale))
+			to := from.Add(image.Pt(int(float64(v[0])/flowScale), int(float64(v[1])/flowScale)))
+			gocv.ArrowedLine(&img, from, to, c, 1)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"gocv.io/x/gocv"
+)
+
+// The classifier only says what is in a frame, not where, so following
+// needs an object detection network as well, such as SSD MobileNet for
+// Tensorflow or Caffe, which gives a box for each object it finds.
+var (
+	followModel      = flag.String("follow-model", "", "object detection model, such as SSD MobileNet, that the follow action uses to find the -follow-label")
+	followConfig     = flag.String("follow-config", "", "network description that goes with -follow-model, such as its .pbtxt or .prototxt")
+	followClasses    = flag.String("follow-classes", "", "file of the class names of -follow-model, one per line in class id order")
+	followLabel      = flag.String("follow-label", "person", "class that the follow action keeps in the middle of the frame")
+	followConfidence = flag.Float64("follow-confidence", 0.5, "minimum detection confidence for the -follow-label to count")
+	followLost       = flag.Int("follow-lost", 30, "how many frames in a row without the -follow-label before following stops and the drone hovers")
+	followTurn       = flag.Int("follow-turn", 40, "most yaw command percent used to follow a target")
+	followClimb      = flag.Int("follow-climb", 30, "most throttle command percent used to follow a target")
+)
+
+// detectorSize is the size SSD networks take their input at.
+var detectorSize = image.Pt(300, 300)
+
+// detectionBox is one object the detector found.
+type detectionBox struct {
+	label      string
+	confidence float32
+	box        image.Rectangle
+}
+
+// objectDetector runs an SSD style detection network, whose output is a
+// row of seven values for each object found: the image, the class id, the
+// confidence and the box corners as fractions of the frame.
+type objectDetector struct {
+	net     gocv.Net
+	classes []string
+}
+
+func newObjectDetector(model, config, classesFile string) (*objectDetector, error) {
+	var classes []string
+	if classesFile != "" {
+		var err error
+		if classes, err = readDescriptions(classesFile); err != nil {
+			return nil, err
+		}
+	}
+	net := gocv.ReadNet(model, config)
+	if net.Empty() {
+		net.Close()
+		return nil, fmt.Errorf("could not read the detection model %v", model)
+	}
+	net.SetPreferableBackend(gocv.ParseNetBackend(*backend))
+	net.SetPreferableTarget(gocv.ParseNetTarget(*target))
+	return &objectDetector{net: net, classes: classes}, nil
+}
+
+// Close releases the network.
+func (d *objectDetector) Close() error {
+	return d.net.Close()
+}
+
+// detect returns the objects found in img with at least minConfidence.
+func (d *objectDetector) detect(img gocv.Mat, minConfidence float32) ([]detectionBox, error) {
+	blob := gocv.BlobFromImage(img, 1/127.5, detectorSize, gocv.NewScalar(127.5, 127.5, 127.5, 0), true, false)
+	defer blob.Close()
+
+	d.net.SetInput(blob, "")
+	out := d.net.Forward("")
+	defer out.Close()
+	data, err := out.DataPtrFloat32()
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := float32(img.Cols()), float32(img.Rows())
+	var found []detectionBox
+	for i := 0; i+7 <= len(data); i += 7 {
+		row := data[i : i+7]
+		if row[2] < minConfidence {
+			continue
+		}
+		found = append(found, detectionBox{
+			label:      d.class(int(row[1])),
+			confidence: row[2],
+			box:        image.Rect(int(row[3]*w), int(row[4]*h), int(row[5]*w), int(row[6]*h)),
+		})
+	}
+	return found, nil
+}
+
+// class returns the name of class id, or the id itself without a
+// -follow-classes file.
+func (d *objectDetector) class(id int) string {
+	if id >= 0 && id < len(d.classes) {
+		return d.classes[id]
+	}
+	return fmt.Sprint(id)
+}
+
+// followTarget is what to follow and when to give up on it. It picks the
+// target out of each frame's detections and counts the frames it was
+// missing from in a row.
+type followTarget struct {
+	label      string
+	confidence float32
+	lostFrames int
+
+	lost int
+}
+
+// observe picks the most confident detection of the target out of boxes,
+// returning false if there is none. lostTooLong reports whether it has
+// been missing for long enough to give up.
+func (t *followTarget) observe(boxes []detectionBox) (image.Rectangle, bool) {
+	var best *detectionBox
+	for i := range boxes {
+		b := &boxes[i]
+		if b.label == t.label && b.confidence >= t.confidence && (best == nil || b.confidence > best.confidence) {
+			best = b
+		}
+	}
+	if best == nil {
+		t.lost++
+		return image.Rectangle{}, false
+	}
+	t.lost = 0
+	return best.box, true
+}
+
+// lostTooLong reports whether the target has been missing from too many
+// frames in a row.
+func (t *followTarget) lostTooLong() bool {
+	return t.lost >= t.lostFrames
+}
+
+var (
+	// followMode is 1 while the drone is following the target
+	followMode int32
+
+	followMu  sync.Mutex
+	followBox image.Rectangle
+	followX   float64
+	followY   float64
+	followHas bool
+)
+
+// followProcessor is a FrameProcessor that finds the target in each frame
+// while following, and stops following once it has been lost for long
+// enough, leaving the drone hovering.
+type followProcessor struct {
+	detector *objectDetector
+	target   *followTarget
+}
+
+func newFollowProcessor(detector *objectDetector) *followProcessor {
+	return &followProcessor{
+		detector: detector,
+		target: &followTarget{
+			label:      *followLabel,
+			confidence: float32(*followConfidence),
+			lostFrames: *followLost,
+		},
+	}
+}
+
+// Close releases the detector.
+func (p *followProcessor) Close() error {
+	return p.detector.Close()
+}
+
+// Process implements FrameProcessor.
+func (p *followProcessor) Process(img gocv.Mat, result ClassificationResult) {
+	if !isFollowing() {
+		p.target.lost = 0
+		return
+	}
+	boxes, err := p.detector.detect(img, p.target.confidence)
+	if err != nil {
+		log.Println("follow:", err)
+	}
+	box, found := p.target.observe(boxes)
+
+	followMu.Lock()
+	followHas = found
+	if found {
+		center := box.Min.Add(box.Max).Div(2)
+		followBox = box
+		followX = 2*float64(center.X)/float64(img.Cols()) - 1
+		followY = 2*float64(center.Y)/float64(img.Rows()) - 1
+	}
+	followMu.Unlock()
+
+	if p.target.lostTooLong() && atomic.CompareAndSwapInt32(&followMode, 1, 0) {
+		log.Printf("lost the %v for %d frames, hovering", p.target.label, p.target.lost)
+	}
+}
+
+// startFollow starts following the target.
+func startFollow() {
+	followMu.Lock()
+	followHas = false
+	followMu.Unlock()
+
+	atomic.StoreInt32(&followMode, 1)
+}
+
+// stopFollow stops following, leaving the drone under manual control.
+func stopFollow() {
+	atomic.StoreInt32(&followMode, 0)
+}
+
+// isFollowing reports whether the drone is following the target.
+func isFollowing() bool {
+	return atomic.LoadInt32(&followMode) == 1
+}
+
+// followCommands returns the yaw and throttle commands that keep the
+// target in the middle of the frame, positive for clockwise and up, and
+// false if the drone is not following or the target is out of sight,
+// when it hovers.
+func followCommands() (yaw, climb int, ok bool) {
+	if !isFollowing() {
+		return 0, 0, false
+	}
+	followMu.Lock()
+	defer followMu.Unlock()
+
+	if !followHas {
+		return 0, 0, false
+	}
+	return steer(followX, *followTurn), -steer(followY, *followClimb), true
+}
+
+// drawFollow outlines the target while following it.
+func drawFollow(img *gocv.Mat) {
+	if !isFollowing() {
+		return
+	}
+	followMu.Lock()
+	defer followMu.Unlock()
+
+	if followHas {
+		gocv.Rectangle(img, followBox, color.RGBA{0, 255, 255, 0}, 2)
+	}
+}
+
+// followStatus describes following for the overlay, or returns "" when
+// the drone is not following.
+func followStatus() string {
+	if !isFollowing() {
+		return ""
+	}
+	followMu.Lock()
+	defer followMu.Unlock()
+
+	if !followHas {
+		return "following: looking for " + *followLabel
+	}
+	return "following " + *followLabel
+}
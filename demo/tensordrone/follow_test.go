@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFollowTarget(t *testing.T) {
+	target := &followTarget{label: "person", confidence: 0.5, lostFrames: 2}
+
+	near := image.Rect(0, 0, 10, 10)
+	far := image.Rect(50, 50, 60, 60)
+	dog := image.Rect(20, 20, 30, 30)
+
+	// each frame is observed in turn by the same target
+	frames := []struct {
+		name        string
+		boxes       []detectionBox
+		box         image.Rectangle
+		found       bool
+		lostTooLong bool
+	}{
+		{
+			name: "most confident of the label",
+			boxes: []detectionBox{
+				{label: "person", confidence: 0.6, box: far},
+				{label: "person", confidence: 0.9, box: near},
+				{label: "dog", confidence: 0.95, box: dog},
+			},
+			box:   near,
+			found: true,
+		},
+		{
+			name:  "wrong label",
+			boxes: []detectionBox{{label: "dog", confidence: 0.99, box: dog}},
+		},
+		{
+			name:        "below confidence",
+			boxes:       []detectionBox{{label: "person", confidence: 0.4, box: far}},
+			lostTooLong: true,
+		},
+		{
+			name:  "seen again at the confidence",
+			boxes: []detectionBox{{label: "person", confidence: 0.5, box: far}},
+			box:   far,
+			found: true,
+		},
+		{
+			name: "missing once since",
+		},
+		{
+			name:        "missing for lostFrames",
+			lostTooLong: true,
+		},
+	}
+	for _, f := range frames {
+		box, found := target.observe(f.boxes)
+		if box != f.box || found != f.found {
+			t.Errorf("%v: observe = %v, %v, want %v, %v", f.name, box, found, f.box, f.found)
+		}
+		if lost := target.lostTooLong(); lost != f.lostTooLong {
+			t.Errorf("%v: lostTooLong = %v, want %v", f.name, lost, f.lostTooLong)
+		}
+	}
+}
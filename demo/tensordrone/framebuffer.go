@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// frameBuffer is a double buffer between the camera callback, which
+// produces processed frames, and the display loop, which shows them. The
+// display always has a whole frame to show, even while an inference spike
+// is holding up the next one.
+//
+// The buffer owns both Mats. The ready Mat belongs to the producer and is
+// only touched with the lock held. The shown Mat belongs to the display
+// and stays valid until its next call to take.
+type frameBuffer struct {
+	sync.Mutex
+	ready gocv.Mat
+	fresh bool
+	shown gocv.Mat
+}
+
+func newFrameBuffer() *frameBuffer {
+	return &frameBuffer{ready: gocv.NewMat(), shown: gocv.NewMat()}
+}
+
+// put copies a fully processed frame into the buffer, replacing any frame
+// that has not been shown yet.
+func (b *frameBuffer) put(img gocv.Mat) {
+	b.Lock()
+	defer b.Unlock()
+
+	img.CopyTo(&b.ready)
+	b.fresh = true
+}
+
+// take returns the newest frame for the display, and false if nothing has
+// been put since the last call, when the frame already shown is still the
+// newest.
+func (b *frameBuffer) take() (gocv.Mat, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.fresh {
+		return b.shown, false
+	}
+	b.ready, b.shown = b.shown, b.ready
+	b.fresh = false
+	return b.shown, true
+}
+
+// Close releases both frames. Neither may be used afterwards.
+func (b *frameBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	b.ready.Close()
+	return b.shown.Close()
+}
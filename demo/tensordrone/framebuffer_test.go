@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+func TestFrameBuffer(t *testing.T) {
+	b := newFrameBuffer()
+
+	if _, ok := b.take(); ok {
+		t.Fatal("took a frame before any was put")
+	}
+
+	// frames are told apart by their size
+	small := gocv.NewMatWithSize(2, 2, gocv.MatTypeCV8UC3)
+	defer small.Close()
+	large := gocv.NewMatWithSize(4, 4, gocv.MatTypeCV8UC3)
+	defer large.Close()
+
+	b.put(small)
+	b.put(large)
+	frame, ok := b.take()
+	if !ok || frame.Rows() != 4 {
+		t.Fatalf("take = %d rows, %v, want the newest frame of 4 rows", frame.Rows(), ok)
+	}
+
+	if _, ok := b.take(); ok {
+		t.Error("took a frame again with nothing new put")
+	}
+
+	// a put leaves the frame shown alone until the next take
+	b.put(small)
+	if frame.Rows() != 4 {
+		t.Errorf("frame shown changed to %d rows by a put, want 4", frame.Rows())
+	}
+	if frame, ok = b.take(); !ok || frame.Rows() != 2 {
+		t.Errorf("take = %d rows, %v, want the frame put of 2 rows", frame.Rows(), ok)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sync"
+)
+
+var fenceRadius = flag.Float64("fence", 0, "experimental: keep the drone within this many meters of where it took off, by its estimated position, slowing and then stopping it flying further out (0 for off)")
+
+// fenceWarn is the fraction of the fence radius from which the drone is
+// slowed on its way out, and warned about.
+const fenceWarn = 0.8
+
+// geofence keeps the drone inside a circle around where it took off. The
+// estimated flight path is all it has to go on, so it drifts from where
+// the drone really is, and the fence with it: it is a backstop for an
+// indoor demo, not something to rely on. It is nil without -fence.
+type geofence struct {
+	radius float64
+	track  *flightTrack
+
+	sync.Mutex
+	originX, originY float64
+}
+
+func newGeofence(radius float64, track *flightTrack) *geofence {
+	if radius <= 0 || track == nil {
+		return nil
+	}
+	return &geofence{radius: radius, track: track}
+}
+
+// tookOff puts the middle of the fence where the drone is.
+func (g *geofence) tookOff() {
+	if g == nil {
+		return
+	}
+	x, y, _ := g.track.position()
+	g.Lock()
+	defer g.Unlock()
+
+	g.originX, g.originY = x, y
+}
+
+// offset returns where the drone is from the middle of the fence, and its
+// heading.
+func (g *geofence) offset() (x, y, heading float64) {
+	x, y, heading = g.track.position()
+	g.Lock()
+	defer g.Unlock()
+
+	return x - g.originX, y - g.originY, heading
+}
+
+// factor returns how much of an outward command is let through at
+// distance d from the middle of the fence: all of it until fenceWarn of
+// the way out, falling to none at the fence.
+func (g *geofence) factor(d float64) float64 {
+	switch inner := fenceWarn * g.radius; {
+	case d <= inner:
+		return 1
+	case d >= g.radius:
+		return 0
+	default:
+		return (g.radius - d) / (g.radius - inner)
+	}
+}
+
+// limit scales down the part of the pitch and roll commands, positive for
+// forward and right, that would take the drone further from the middle of
+// the fence, leaving the part along or inside it alone.
+func (g *geofence) limit(pitch, roll int) (int, int) {
+	if g == nil || (pitch == 0 && roll == 0) {
+		return pitch, roll
+	}
+	x, y, heading := g.offset()
+	d := math.Hypot(x, y)
+	f := g.factor(d)
+	if f == 1 {
+		return pitch, roll
+	}
+
+	// the command as a direction on the ground, the same way as the
+	// flight path is estimated
+	rad := heading * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	a, r := float64(pitch), float64(roll)
+	vx, vy := a*sin+r*cos, a*cos-r*sin
+
+	ux, uy := x/d, y/d
+	out := vx*ux + vy*uy
+	if out <= 0 {
+		return pitch, roll
+	}
+	vx -= (1 - f) * out * ux
+	vy -= (1 - f) * out * uy
+
+	return int(math.Round(vx*sin + vy*cos)), int(math.Round(vx*cos - vy*sin))
+}
+
+// status describes the drone nearing or at the fence for the overlay, with
+// true once it is at it, or returns "" while it is well inside.
+func (g *geofence) status() (string, bool) {
+	if g == nil {
+		return "", false
+	}
+	x, y, _ := g.offset()
+	d := math.Hypot(x, y)
+	switch {
+	case d >= g.radius:
+		return fmt.Sprintf("warning: at the fence, %.1fm out", d), true
+	case d > fenceWarn*g.radius:
+		return fmt.Sprintf("nearing the fence, %.1fm out", d), false
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var historyLength = flag.Int("history", 0, "show the last N classifications, with when they were seen, in a sidebar next to the video")
+
+// sidebarWidth is how wide the history sidebar is, in pixels.
+const sidebarWidth = 320
+
+// historyEntry is a classification and when it was first seen.
+type historyEntry struct {
+	label string
+	at    time.Time
+}
+
+// history is the most recent classifications, newest first, drawn in a
+// sidebar beside the video. It is only used from the camera callback.
+type history struct {
+	size    int
+	entries []historyEntry
+	canvas  gocv.Mat
+}
+
+// newHistory returns a history of size entries, or nil if size is zero.
+func newHistory(size int) *history {
+	if size <= 0 {
+		return nil
+	}
+	return &history{size: size, canvas: gocv.NewMat()}
+}
+
+// Close releases the canvas.
+func (h *history) Close() error {
+	if h == nil {
+		return nil
+	}
+	return h.canvas.Close()
+}
+
+// add records label, if it is not the same as the last one.
+func (h *history) add(label string, at time.Time) {
+	if h == nil {
+		return
+	}
+	if len(h.entries) > 0 && h.entries[0].label == label {
+		return
+	}
+	h.entries = append([]historyEntry{{label: label, at: at}}, h.entries...)
+	if len(h.entries) > h.size {
+		h.entries = h.entries[:h.size]
+	}
+}
+
+// compose returns img with the history sidebar to its right. The result
+// is only valid until the next call.
+func (h *history) compose(img gocv.Mat, labels translations) gocv.Mat {
+	if h == nil {
+		return img
+	}
+
+	rows, cols := img.Rows(), img.Cols()+sidebarWidth
+	if h.canvas.Rows() != rows || h.canvas.Cols() != cols || h.canvas.Type() != img.Type() {
+		h.canvas.Close()
+		h.canvas = gocv.NewMatWithSize(rows, cols, img.Type())
+	}
+
+	video := h.canvas.Region(image.Rect(0, 0, img.Cols(), rows))
+	img.CopyTo(&video)
+	video.Close()
+
+	sidebar := image.Rect(img.Cols(), 0, cols, rows)
+	gocv.Rectangle(&h.canvas, sidebar, color.RGBA{0, 0, 0, 0}, -1)
+
+	y := overlayMargin
+	for _, e := range h.entries {
+		line := e.at.Format("15:04:05") + " " + labels.translate(e.label)
+		size, baseline := gocv.GetTextSizeWithBaseline(line, overlayFont, overlayScale, overlayThickness)
+		y += size.Y
+		if y+baseline > rows {
+			break
+		}
+		gocv.PutText(&h.canvas, line, image.Pt(sidebar.Min.X+overlayMargin, y), overlayFont, overlayScale, overlayColor, overlayThickness)
+		y += baseline + overlaySpacing
+	}
+	return h.canvas
+}
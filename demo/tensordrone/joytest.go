@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/joystick"
+	"gobot.io/x/gobot/platforms/opencv"
+	"gocv.io/x/gocv"
+)
+
+var joyTest = flag.Bool("joytest", false, "show live joystick axes and buttons without a drone, to check a mapping file")
+
+// joystickMapping is the part of a joystick JSON mapping file that names
+// the axes and buttons.
+type joystickMapping struct {
+	Name string `json:"name"`
+	Axis []struct {
+		Name string `json:"name"`
+		ID   int    `json:"id"`
+	} `json:"axis"`
+	Buttons []struct {
+		Name string `json:"name"`
+	} `json:"buttons"`
+}
+
+// joystickState is the latest value of every axis and button seen.
+type joystickState struct {
+	sync.Mutex
+	axes    map[string]int16
+	buttons map[string]bool
+}
+
+// update records a joystick event.
+func (s *joystickState) update(name string, data interface{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case strings.HasSuffix(name, "_press"):
+		s.buttons[strings.TrimSuffix(name, "_press")] = true
+	case strings.HasSuffix(name, "_release"):
+		s.buttons[strings.TrimSuffix(name, "_release")] = false
+	default:
+		if val, ok := data.(int16); ok {
+			s.axes[name] = val
+		}
+	}
+}
+
+// runJoystickTest opens only the joystick and a window, and shows the
+// joystick state until interrupted.
+func runJoystickTest(joystickFile string) {
+	state := &joystickState{
+		axes:    make(map[string]int16),
+		buttons: make(map[string]bool),
+	}
+
+	// show every axis and button in the mapping, even before it is used
+	if b, err := ioutil.ReadFile(joystickFile); err == nil {
+		var mapping joystickMapping
+		if err := json.Unmarshal(b, &mapping); err == nil {
+			for _, a := range mapping.Axis {
+				state.axes[a.Name] = 0
+			}
+			for _, b := range mapping.Buttons {
+				state.buttons[b.Name] = false
+			}
+		}
+	}
+
+	joystickAdaptor := joystick.NewAdaptor()
+	stick := joystick.NewDriver(joystickAdaptor, joystickFile)
+	window := opencv.NewWindowDriver()
+
+	work := func() {
+		events := stick.Subscribe()
+		go func() {
+			for evt := range events {
+				state.update(evt.Name, evt.Data)
+			}
+		}()
+
+		canvas := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+		gobot.Every(50*time.Millisecond, func() {
+			drawJoystickState(&canvas, state)
+			window.ShowImage(canvas)
+			window.WaitKey(1)
+		})
+	}
+
+	robot := gobot.NewRobot("joytest",
+		[]gobot.Connection{joystickAdaptor},
+		[]gobot.Device{stick, window},
+		work,
+	)
+
+	robot.Start()
+}
+
+// drawJoystickState draws a bar for each axis and a box for each button,
+// filled in while it is held down.
+func drawJoystickState(canvas *gocv.Mat, state *joystickState) {
+	state.Lock()
+	defer state.Unlock()
+
+	canvas.SetTo(gocv.NewScalar(0, 0, 0, 0))
+	white := color.RGBA{255, 255, 255, 0}
+	green := color.RGBA{0, 255, 0, 0}
+
+	y := 30
+	for _, name := range sortedKeys(state.axes) {
+		val := state.axes[name]
+		gocv.PutText(canvas, fmt.Sprintf("%v: %d", name, val), image.Pt(10, y), gocv.FontHersheyPlain, 1.2, white, 1)
+
+		// the bar grows left or right from the middle with the axis value
+		mid := 400
+		end := mid + int(float64(val)/controls.Offset*200)
+		bar := image.Rect(mid, y-12, end, y).Canon()
+		gocv.Rectangle(canvas, image.Rect(200, y-12, 600, y), white, 1)
+		gocv.Rectangle(canvas, bar, green, -1)
+		y += 25
+	}
+
+	y += 15
+	x := 10
+	for _, name := range sortedButtons(state.buttons) {
+		box := image.Rect(x, y-18, x+110, y+6)
+		if state.buttons[name] {
+			gocv.Rectangle(canvas, box, green, -1)
+		} else {
+			gocv.Rectangle(canvas, box, white, 1)
+		}
+		gocv.PutText(canvas, name, image.Pt(x+5, y), gocv.FontHersheyPlain, 1.2, white, 1)
+
+		x += 120
+		if x+110 > canvas.Cols() {
+			x = 10
+			y += 35
+		}
+	}
+}
+
+func sortedKeys(m map[string]int16) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedButtons(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var keysFlag = flag.String("keys", "", "rebind keyboard keys, such as up=i,down=k,forward=w")
+
+// keyHold is how long a movement key keeps the drone moving after it was
+// last seen. Holding a key down repeats it, so this carries the movement
+// over to the next repeat.
+const keyHold = 150 * time.Millisecond
+
+// keyPower is how far, from 0 to 1, a movement key pushes its stick.
+const keyPower = 0.5
+
+// defaultKeys maps each keyboard action to its key. The button actions,
+// such as takeoff, do the same as their joystick buttons. An action bound
+// to "" has no key, as emergency has by default: it stops the motors in
+// the air, and esc is too easily pressed by mistake, such as to leave a
+// window.
+var defaultKeys = map[string]string{
+	"forward":           "w",
+	"backward":          "s",
+	"left":              "a",
+	"right":             "d",
+	"up":                "i",
+	"down":              "k",
+	"counter-clockwise": "j",
+	"clockwise":         "l",
+	"arm":               "r",
+	"takeoff":           "t",
+	"land":              "g",
+	"stop":              "space",
+	"emergency":         "",
+	"pause":             "p",
+	"camera":            "c",
+	"record":            "v",
+	"lock":              "e",
+	"reload":            "m",
+	"follow":            "f",
+
+	"layer-classification": "1",
+	"layer-regions":        "2",
+	"layer-status":         "3",
+	"layer-battery":        "4",
+	"layer-commands":       "5",
+	"layer-history":        "6",
+}
+
+// namedKeys are the keys that are given by name rather than by character.
+var namedKeys = map[string]int{
+	"space":     32,
+	"esc":       27,
+	"enter":     13,
+	"tab":       9,
+	"backspace": 8,
+}
+
+// stickKey is which stick axis a movement key pushes, and which way.
+type stickKey struct {
+	axis *atomic.Value
+	sign float64
+}
+
+var movementKeys = map[string]stickKey{
+	"forward":           {&rightY, -1},
+	"backward":          {&rightY, 1},
+	"left":              {&rightX, -1},
+	"right":             {&rightX, 1},
+	"up":                {&leftY, -1},
+	"down":              {&leftY, 1},
+	"counter-clockwise": {&leftX, -1},
+	"clockwise":         {&leftX, 1},
+}
+
+// parseKeys applies the comma separated action=key pairs in spec on top of
+// the default keys, returning a table of key code to action.
+func parseKeys(spec string) (map[int]string, error) {
+	keys := make(map[string]string, len(defaultKeys))
+	for action, key := range defaultKeys {
+		keys[action] = key
+	}
+
+	if strings.TrimSpace(spec) != "" {
+		for _, pair := range strings.Split(spec, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("key %q is not in the form action=key", pair)
+			}
+
+			action := strings.ToLower(strings.TrimSpace(parts[0]))
+			if _, ok := defaultKeys[action]; !ok {
+				return nil, fmt.Errorf("unknown key action %q, expected one of %v", action, strings.Join(keyActionNames(), ", "))
+			}
+			keys[action] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	table := make(map[int]string, len(keys))
+	for _, action := range keyActionNames() {
+		if keys[action] == "" {
+			continue
+		}
+		code, err := keyCode(keys[action])
+		if err != nil {
+			return nil, fmt.Errorf("key for %v: %v", action, err)
+		}
+		if other, ok := table[code]; ok {
+			return nil, fmt.Errorf("key %q is bound to both %v and %v", keys[action], other, action)
+		}
+		table[code] = action
+	}
+	return table, nil
+}
+
+// keyCode returns the code WaitKey gives for key, which is either a single
+// character or one of the named keys.
+func keyCode(key string) (int, error) {
+	if code, ok := namedKeys[strings.ToLower(key)]; ok {
+		return code, nil
+	}
+	if len(key) != 1 || key[0] < '!' || key[0] > '~' {
+		return 0, fmt.Errorf("%q is not a single character or one of space, esc, enter, tab or backspace", key)
+	}
+	return int(key[0]), nil
+}
+
+func keyActionNames() []string {
+	names := make([]string, 0, len(defaultKeys))
+	for action := range defaultKeys {
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keyboard flies the drone from key presses in the video window.
+type keyboard struct {
+	keys    map[int]string
+	actions map[string]func()
+
+	sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newKeyboard(keys map[int]string, actions map[string]func()) *keyboard {
+	return &keyboard{keys: keys, actions: actions, timers: make(map[string]*time.Timer)}
+}
+
+// press handles a key code returned by WaitKey.
+func (k *keyboard) press(code int) {
+	action, ok := k.keys[code&0xff]
+	if !ok {
+		return
+	}
+
+	if move, ok := movementKeys[action]; ok {
+		k.move(action, move)
+		return
+	}
+	if do, ok := k.actions[action]; ok {
+		do()
+	}
+}
+
+// move pushes the stick for a movement key, letting it go again once the
+// key has not been seen for keyHold.
+func (k *keyboard) move(action string, move stickKey) {
+	k.Lock()
+	defer k.Unlock()
+
+	move.axis.Store(move.sign * keyPower * controls.Offset)
+	if t, ok := k.timers[action]; ok {
+		t.Reset(keyHold)
+		return
+	}
+	k.timers[action] = time.AfterFunc(keyHold, func() {
+		move.axis.Store(float64(0))
+		k.Lock()
+		delete(k.timers, action)
+		k.Unlock()
+	})
+}
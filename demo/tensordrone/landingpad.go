@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	landingPad = flag.Bool("landing-pad", false, "with a camera looking down, center over a dark square marker on a light floor before landing")
+	padSpeed   = flag.Int("pad-speed", 15, "most pitch and roll command percent used to center over the landing pad")
+	padTimeout = flag.Duration("pad-timeout", 5*time.Second, "how long to try to center over the landing pad before landing anyway")
+)
+
+const (
+	// padMinArea is the smallest fraction of the frame a contour must
+	// fill to be the landing pad, so that specks are never taken for it.
+	padMinArea = 0.01
+	// padDeadband is how far off center, from 0 to 1, the pad may be and
+	// still count as centered.
+	padDeadband = 0.1
+	// padSettle is how many checks in a row the pad must be centered for
+	// before descending.
+	padSettle = 10
+	// padStale is how long a sighting of the pad is steered by.
+	padStale = 300 * time.Millisecond
+)
+
+// padSighting is where the landing pad was last seen, as its outline and
+// its offset from the middle of the frame, from -1 to 1, with y positive
+// towards the bottom.
+type padSighting struct {
+	at   time.Time
+	box  image.Rectangle
+	x, y float64
+}
+
+var (
+	lastPad atomic.Value
+
+	// centering is 1 while the drone is centering over the pad to land
+	centering int32
+
+	padMu       sync.Mutex
+	padDeadline time.Time
+	padCentered int
+)
+
+// padDetector is a FrameProcessor that looks for the landing pad, a dark
+// square marker, as the largest four sided outline in the frame.
+type padDetector struct {
+	gray gocv.Mat
+}
+
+func newPadDetector() *padDetector {
+	return &padDetector{gray: gocv.NewMat()}
+}
+
+// Close releases the working image.
+func (p *padDetector) Close() error {
+	return p.gray.Close()
+}
+
+// Process implements FrameProcessor.
+func (p *padDetector) Process(img gocv.Mat, result ClassificationResult) {
+	gocv.CvtColor(img, &p.gray, gocv.ColorBGRToGray)
+	gocv.Threshold(p.gray, &p.gray, 0, 255, gocv.ThresholdBinaryInv|gocv.ThresholdOtsu)
+
+	contours := gocv.FindContours(p.gray, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	minArea := padMinArea * float64(img.Cols()*img.Rows())
+	var best image.Rectangle
+	bestArea := 0.0
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		area := gocv.ContourArea(c)
+		if area < minArea || area <= bestArea {
+			continue
+		}
+		corners := gocv.ApproxPolyDP(c, 0.04*gocv.ArcLength(c, true), true)
+		square := corners.Size() == 4
+		corners.Close()
+		box := gocv.BoundingRect(c)
+		if ratio := float64(box.Dx()) / float64(box.Dy()); !square || ratio < 0.5 || ratio > 2 {
+			continue
+		}
+		best, bestArea = box, area
+	}
+	if bestArea == 0 {
+		return
+	}
+
+	center := best.Min.Add(best.Max).Div(2)
+	lastPad.Store(padSighting{
+		at:  time.Now(),
+		box: best,
+		x:   2*float64(center.X)/float64(img.Cols()) - 1,
+		y:   2*float64(center.Y)/float64(img.Rows()) - 1,
+	})
+}
+
+// drawPad outlines the landing pad, if it has just been seen.
+func drawPad(img *gocv.Mat) {
+	if s, ok := lastPad.Load().(padSighting); ok && time.Since(s.at) < padStale {
+		gocv.Rectangle(img, s.box, color.RGBA{255, 0, 255, 0}, 2)
+	}
+}
+
+// startCentering starts centering over the landing pad before landing.
+func startCentering(now time.Time) {
+	padMu.Lock()
+	padDeadline = now.Add(*padTimeout)
+	padCentered = 0
+	padMu.Unlock()
+
+	atomic.StoreInt32(&centering, 1)
+}
+
+// isCentering reports whether the drone is centering over the landing pad.
+func isCentering() bool {
+	return atomic.LoadInt32(&centering) == 1
+}
+
+// stopCentering stops centering, leaving the drone under manual control.
+func stopCentering() {
+	atomic.StoreInt32(&centering, 0)
+}
+
+// padCommands returns the pitch and roll commands that move the drone over
+// the landing pad, positive for forward and right, taking the top of the
+// frame as forward. It returns done once the drone has been over the pad
+// for long enough, or has run out of time to find it, when it should land,
+// and false if it is not centering.
+func padCommands(now time.Time) (pitch, roll int, done, ok bool) {
+	if !isCentering() {
+		return 0, 0, false, false
+	}
+	padMu.Lock()
+	defer padMu.Unlock()
+
+	if now.After(padDeadline) {
+		log.Println("could not center over the landing pad in time, landing anyway")
+		stopCentering()
+		return 0, 0, true, true
+	}
+
+	s, seen := lastPad.Load().(padSighting)
+	if !seen || now.Sub(s.at) > padStale {
+		// hover until the pad is seen again or the time is up
+		padCentered = 0
+		return 0, 0, false, true
+	}
+	if math.Abs(s.x) < padDeadband && math.Abs(s.y) < padDeadband {
+		padCentered++
+		if padCentered >= padSettle {
+			stopCentering()
+			return 0, 0, true, true
+		}
+		return 0, 0, false, true
+	}
+	padCentered = 0
+	return int(-s.y * float64(*padSpeed)), int(s.x * float64(*padSpeed)), false, true
+}
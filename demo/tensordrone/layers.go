@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+var hideLayers = flag.String("hide-layers", "", "comma separated overlay layers to hide at startup, of classification, regions, status, battery, commands and history")
+
+// layerNames are the overlay layers, in the order they are drawn. Each can
+// be shown or hidden with its own layer- action, such as layer-status.
+// Warnings, the menu and the banner are not layers, so that they cannot be
+// hidden by mistake.
+var layerNames = []string{"classification", "regions", "status", "battery", "commands", "history"}
+
+// layerFrame is what the layers draw from for one frame.
+type layerFrame struct {
+	img     *gocv.Mat
+	overlay *overlayLayout
+	result  ClassificationResult
+	ranked  []prediction
+	subject image.Rectangle
+}
+
+// overlayLayer is one named part of the overlay. Marks layers draw on the
+// video itself, before it is mirrored with -mirror-display, and the others
+// draw text over it once it has been.
+type overlayLayer struct {
+	name  string
+	marks bool
+	draw  func(f *layerFrame)
+}
+
+var (
+	layersMu sync.Mutex
+	layers   []overlayLayer
+	hidden   = make(map[string]bool)
+)
+
+// setupLayers hides the layers in the comma separated list hide.
+func setupLayers(hide string) error {
+	for _, name := range strings.Split(hide, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !isLayer(name) {
+			return fmt.Errorf("-hide-layers: unknown layer %q, expected one of %v", name, strings.Join(layerNames, ", "))
+		}
+		hidden[name] = true
+	}
+	return nil
+}
+
+func isLayer(name string) bool {
+	for _, n := range layerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// addLayer registers the drawing for the layer name. Layers are drawn in
+// the order they are added.
+func addLayer(name string, marks bool, draw func(f *layerFrame)) {
+	if !isLayer(name) {
+		panic("unknown overlay layer " + name)
+	}
+	layersMu.Lock()
+	defer layersMu.Unlock()
+
+	layers = append(layers, overlayLayer{name: name, marks: marks, draw: draw})
+}
+
+// toggleLayer shows the layer name if it is hidden, and hides it if not.
+func toggleLayer(name string) {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+
+	hidden[name] = !hidden[name]
+}
+
+// layerShown reports whether the layer name is being shown.
+func layerShown(name string) bool {
+	layersMu.Lock()
+	defer layersMu.Unlock()
+
+	return !hidden[name]
+}
+
+// drawLayers draws the shown layers that are marks layers, or that are
+// not, in order.
+func drawLayers(f *layerFrame, marks bool) {
+	layersMu.Lock()
+	var shown []overlayLayer
+	for _, l := range layers {
+		if l.marks == marks && !hidden[l.name] {
+			shown = append(shown, l)
+		}
+	}
+	layersMu.Unlock()
+
+	for _, l := range shown {
+		l.draw(f)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+var calibrationFile = flag.String("calibration", "", "undistort frames with the lens calibration in this file, made with the calibrate command")
+
+// minCalibrationViews is how many views of the chessboard are needed
+// before the lens can be calibrated.
+const minCalibrationViews = 10
+
+// lensCalibration is the on-disk form of a camera's intrinsics and lens
+// distortion, for the frame size it was measured at.
+type lensCalibration struct {
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CameraMatrix []float64 `json:"camera_matrix"`
+	Distortion   []float64 `json:"distortion"`
+	Error        float64   `json:"rms_error"`
+}
+
+// undistorter removes lens distortion from frames. It is nil when there
+// is no calibration, and then leaves frames alone.
+type undistorter struct {
+	size       image.Point
+	camera     gocv.Mat
+	distortion gocv.Mat
+	out        gocv.Mat
+	warned     bool
+}
+
+// newUndistorter reads a lens calibration file, returning nil if path is
+// empty.
+func newUndistorter(path string) (*undistorter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c lensCalibration
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	if len(c.CameraMatrix) != 9 || len(c.Distortion) == 0 {
+		return nil, fmt.Errorf("%v: needs a camera_matrix of 9 values and distortion coefficients", path)
+	}
+
+	u := &undistorter{
+		size:       image.Pt(c.Width, c.Height),
+		camera:     gocv.NewMatWithSize(3, 3, gocv.MatTypeCV64F),
+		distortion: gocv.NewMatWithSize(1, len(c.Distortion), gocv.MatTypeCV64F),
+		out:        gocv.NewMat(),
+	}
+	for i, v := range c.CameraMatrix {
+		u.camera.SetDoubleAt(i/3, i%3, v)
+	}
+	for i, v := range c.Distortion {
+		u.distortion.SetDoubleAt(0, i, v)
+	}
+	return u, nil
+}
+
+// Close releases the calibration.
+func (u *undistorter) Close() error {
+	if u == nil {
+		return nil
+	}
+	u.out.Close()
+	u.distortion.Close()
+	return u.camera.Close()
+}
+
+// undistort returns img with the lens distortion taken out. Frames of
+// another size than the calibration was made at are left alone, as the
+// calibration does not hold for them.
+func (u *undistorter) undistort(img gocv.Mat) gocv.Mat {
+	if u == nil {
+		return img
+	}
+	if img.Cols() != u.size.X || img.Rows() != u.size.Y {
+		if !u.warned {
+			log.Printf("frames are %dx%d but the lens was calibrated at %dx%d, so they are not undistorted",
+				img.Cols(), img.Rows(), u.size.X, u.size.Y)
+			u.warned = true
+		}
+		return img
+	}
+	gocv.Undistort(img, &u.out, u.camera, u.distortion, u.camera)
+	return u.out
+}
+
+// calibrateLens shows the camera, collecting views of a chessboard each
+// time space is pressed, then works out the lens calibration from them and
+// writes it to a file once enter is pressed.
+func calibrateLens(args []string) error {
+	if len(args) < 3 {
+		return errors.New("How to run:\n\ttensordrone calibrate [cameraid] [inner corners, such as 9x6] [calibration file]")
+	}
+	device, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("camera ID %q is not a number", args[0])
+	}
+	var pattern image.Point
+	if _, err := fmt.Sscanf(strings.ToLower(args[1]), "%dx%d", &pattern.X, &pattern.Y); err != nil || pattern.X < 2 || pattern.Y < 2 {
+		return fmt.Errorf("%q is not a number of inner corners such as 9x6", args[1])
+	}
+
+	capture, err := gocv.VideoCaptureDevice(device)
+	if err != nil {
+		return err
+	}
+	defer capture.Close()
+	window := gocv.NewWindow("calibrate")
+	defer window.Close()
+
+	img := gocv.NewMat()
+	defer img.Close()
+	gray := gocv.NewMat()
+	defer gray.Close()
+	corners := gocv.NewMat()
+	defer corners.Close()
+
+	// the chessboard corners, in squares, as they are on the board
+	var board []gocv.Point3f
+	for y := 0; y < pattern.Y; y++ {
+		for x := 0; x < pattern.X; x++ {
+			board = append(board, gocv.Point3f{X: float32(x), Y: float32(y)})
+		}
+	}
+	var objectPoints [][]gocv.Point3f
+	var imagePoints [][]gocv.Point2f
+
+	criteria := gocv.NewTermCriteria(gocv.Count+gocv.EPS, 30, 0.001)
+	for {
+		if !capture.Read(&img) || img.Empty() {
+			continue
+		}
+		gocv.CvtColor(img, &gray, gocv.ColorBGRToGray)
+		found := gocv.FindChessboardCorners(gray, pattern, &corners, gocv.CalibCBAdaptiveThresh|gocv.CalibCBNormalizeImage)
+		if found {
+			gocv.CornerSubPix(gray, &corners, image.Pt(11, 11), image.Pt(-1, -1), criteria)
+		}
+
+		shown := img.Clone()
+		gocv.DrawChessboardCorners(&shown, pattern, corners, found)
+		msg := fmt.Sprintf("%d views, space to add one, enter when done", len(imagePoints))
+		gocv.PutText(&shown, msg, image.Pt(10, 30), overlayFont, overlayScale, color.RGBA{0, 255, 0, 0}, 2)
+		window.IMShow(shown)
+		shown.Close()
+
+		switch window.WaitKey(10) {
+		case 32:
+			if !found {
+				continue
+			}
+			points := gocv.NewPoint2fVectorFromMat(corners)
+			imagePoints = append(imagePoints, points.ToPoints())
+			points.Close()
+			objectPoints = append(objectPoints, board)
+		case 13, 10:
+			if len(imagePoints) < minCalibrationViews {
+				log.Printf("need at least %d views of the chessboard, from different angles", minCalibrationViews)
+				continue
+			}
+			return writeCalibration(args[2], objectPoints, imagePoints, image.Pt(img.Cols(), img.Rows()))
+		case 27:
+			return errors.New("calibration cancelled")
+		}
+	}
+}
+
+// writeCalibration calibrates the lens from the views of the chessboard
+// and writes the result to path.
+func writeCalibration(path string, objectPoints [][]gocv.Point3f, imagePoints [][]gocv.Point2f, size image.Point) error {
+	objects := gocv.NewPoints3fVectorFromPoints(objectPoints)
+	defer objects.Close()
+	images := gocv.NewPoints2fVectorFromPoints(imagePoints)
+	defer images.Close()
+
+	camera := gocv.NewMat()
+	defer camera.Close()
+	distortion := gocv.NewMat()
+	defer distortion.Close()
+	rvecs := gocv.NewMat()
+	defer rvecs.Close()
+	tvecs := gocv.NewMat()
+	defer tvecs.Close()
+
+	rms := gocv.CalibrateCamera(objects, images, size, &camera, &distortion, &rvecs, &tvecs, 0)
+
+	c := lensCalibration{Width: size.X, Height: size.Y, Error: rms}
+	for i := 0; i < 9; i++ {
+		c.CameraMatrix = append(c.CameraMatrix, camera.GetDoubleAt(i/3, i%3))
+	}
+	for i := 0; i < distortion.Total(); i++ {
+		c.Distortion = append(c.Distortion, distortion.GetDoubleAt(0, i))
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("calibrated with an error of %.2f pixels, saved to %v\n", rms, path)
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+
+	"gobot.io/x/gobot/platforms/parrot/minidrone"
+)
+
+// lightAnimation is one of the ways the minidrone can light its LEDs.
+type lightAnimation struct {
+	name      string
+	mode      uint8
+	intensity uint8
+}
+
+// lightAnimations are the animations the lights button cycles through.
+var lightAnimations = []lightAnimation{
+	{name: "off", mode: minidrone.LightFixed, intensity: 0},
+	{name: "on", mode: minidrone.LightFixed, intensity: 100},
+	{name: "blinking", mode: minidrone.LightBlinked},
+	{name: "oscillating", mode: minidrone.LightOscillated},
+}
+
+// lightShow is the animation the drone's LEDs are showing.
+type lightShow struct {
+	sync.Mutex
+	current int
+}
+
+// next moves on to the next animation and returns it.
+func (l *lightShow) next() lightAnimation {
+	l.Lock()
+	defer l.Unlock()
+
+	l.current = (l.current + 1) % len(lightAnimations)
+	return lightAnimations[l.current]
+}
+
+// status returns the name of the animation to show the pilot, or "" while
+// the lights are off.
+func (l *lightShow) status() string {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.current == 0 {
+		return ""
+	}
+	return "lights: " + lightAnimations[l.current].name
+}
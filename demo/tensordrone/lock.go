@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	lockTurn  = flag.Int("lock-turn", 40, "most yaw command percent used to keep a locked on object in the middle of the frame")
+	lockClimb = flag.Int("lock-climb", 30, "most throttle command percent used to keep a locked on object in the middle of the frame")
+)
+
+// steerDeadband is how far off center, from 0 to 1, a locked on or followed
+// object may be before the drone turns or climbs after it, so that it does
+// not hunt.
+const steerDeadband = 0.1
+
+// detection is what the classifier last saw and where: the salient region
+// with -salient, or else nowhere in particular.
+type detection struct {
+	label string
+	box   image.Rectangle
+}
+
+var (
+	lastDetection atomic.Value
+
+	// locked is 1 while the drone is steering to follow the locked object
+	locked int32
+
+	lockMu    sync.Mutex
+	lockLabel string
+)
+
+// detected records the last classification, for locking on to.
+func detected(label string, box image.Rectangle) {
+	lastDetection.Store(detection{label: label, box: box})
+}
+
+// startLock hands over from the classifier to the tracker: it starts
+// tracking what the classifier last saw, in the region it saw it, or in
+// the middle of the frame if there was no region, and steers the drone to
+// keep it in the middle of the frame.
+func startLock(tracker *objectTracker) {
+	d, _ := lastDetection.Load().(detection)
+	lockMu.Lock()
+	lockLabel = d.label
+	lockMu.Unlock()
+
+	tracker.selectRegion(d.box)
+	atomic.StoreInt32(&locked, 1)
+}
+
+// isLocked reports whether the drone is following a locked on object.
+func isLocked() bool {
+	return atomic.LoadInt32(&locked) == 1
+}
+
+// lockedOn returns the label of the locked on object, for the overlay.
+func lockedOn() string {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	return lockLabel
+}
+
+// stopLock stops following, leaving the drone under manual control.
+func stopLock(tracker *objectTracker) {
+	if atomic.CompareAndSwapInt32(&locked, 1, 0) {
+		tracker.stop()
+	}
+}
+
+// lockCommands returns the yaw and throttle commands that keep the locked
+// on object in the middle of the frame, positive for clockwise and up, and
+// false if the drone should not be following one.
+func lockCommands(tracker *objectTracker) (yaw, climb int, ok bool) {
+	if !isLocked() {
+		return 0, 0, false
+	}
+	x, y, tracking := tracker.position()
+	if !tracking {
+		// give up once the object is lost, rather than waiting for it
+		if !tracker.selecting() {
+			atomic.StoreInt32(&locked, 0)
+		}
+		return 0, 0, false
+	}
+	return steer(x, *lockTurn), -steer(y, *lockClimb), true
+}
+
+// steer turns an offset from the middle of the frame, from -1 to 1, into a
+// command of up to max percent, ignoring small offsets. Both locking on and
+// following steer with it.
+func steer(off float64, max int) int {
+	if off > -steerDeadband && off < steerDeadband {
+		return 0
+	}
+	return int(off * float64(max))
+}
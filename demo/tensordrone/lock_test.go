@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestSteer(t *testing.T) {
+	tests := []struct {
+		off  float64
+		want int
+	}{
+		{0, 0},
+		{steerDeadband / 2, 0},
+		{-steerDeadband / 2, 0},
+		{steerDeadband, 4},
+		{-steerDeadband, -4},
+		{0.5, 20},
+		{-0.5, -20},
+		{1, 40},
+		{-1, -40},
+	}
+	for _, tt := range tests {
+		if got := steer(tt.off, 40); got != tt.want {
+			t.Errorf("steer(%v, 40) = %v, want %v", tt.off, got, tt.want)
+		}
+	}
+}
@@ -10,7 +10,489 @@ You must also have the camera on the drone.
 
 How to run
 
-	go run tensordrone/main.go "Mambo_1234" dualshock3.json 0 tensorflow_inception_graph.pb imagenet_comp_graph_label_strings.txt
+	go run ./tensordrone "Mambo_1234" dualshock3.json 0 tensorflow_inception_graph.pb imagenet_comp_graph_label_strings.txt
+
+To run the classifier on a GPU, pass the DNN backend and target:
+
+	go run ./tensordrone -backend cuda -target cuda "Mambo_1234" dualshock3.json 0 tensorflow_inception_graph.pb imagenet_comp_graph_label_strings.txt
+
+If the forward pass keeps returning no output on that target, the demo
+falls back to CPU inference. An OpenCV error in the forward pass cannot be
+caught, and ends the demo, so try a new backend and target on one image
+with the classify command, below, before flying with it.
+
+gocv has no call to set how many threads OpenCV uses, so to leave CPU for
+other programs on a shared machine, set OPENCV_FOR_THREADS_NUM when
+starting the demo:
+
+	OPENCV_FOR_THREADS_NUM=2 go run ./tensordrone ...
+
+Fewer threads use less CPU, at the cost of each frame taking longer to
+classify.
+
+To steady the classification against motion blur and flicker, -batch 5
+averages the probabilities of the last five frames.
+
+Models trained with other than the usual channel order of their format
+need -swap-rb=false for BGR or -swap-rb for RGB, and models trained on
+center crops need -crop. See classify.go for details.
+
+ONNX models work too, and are recognized by their .onnx extension, or
+with -model-format onnx. Their single input and output layers are used
+unless -input-layer and -output-layer name others. Many give raw scores
+rather than probabilities, which -softmax turns into probabilities.
+
+	go run ./tensordrone -softmax "Mambo_1234" dualshock3.json 0 mobilenetv2.onnx imagenet_comp_graph_label_strings.txt
+
+Caffe and Darknet models come as weights and a network description. Give
+the weights as the model file and the description with -model-config; the
+format goes by the .caffemodel or .weights extension, or -model-format.
+Caffe models are given BGR frames with the ImageNet mean taken away, as
+they are trained. Only Darknet classification networks, such as
+Darknet-19, can be used, not YOLO detection ones.
+
+	go run ./tensordrone -model-config deploy.prototxt "Mambo_1234" dualshock3.json 0 bvlc_googlenet.caffemodel synset_words.txt
+	go run ./tensordrone -model-config darknet19.cfg "Mambo_1234" dualshock3.json 0 darknet19.weights imagenet.shortnames.list
+
+To check the model and descriptions against a single image, without any
+camera or drone, use the classify command:
+
+	go run ./tensordrone -top 5 classify banana.jpg tensorflow_inception_graph.pb imagenet_comp_graph_label_strings.txt
+
+To see everything a model can recognize, print its descriptions file with
+the index of each label:
+
+	go run ./tensordrone -list-labels imagenet_comp_graph_label_strings.txt
+
+To check that frames do not leak gocv Mats, which would slowly use up
+memory over a long flight, the matcheck command runs synthetic frames
+through the classifier, the frame processors and the overlay, and fails
+if any Mats are left open. It needs gocv's Mat counting built in:
+
+	go run -tags matprofile ./tensordrone matcheck 100 tensorflow_inception_graph.pb imagenet_comp_graph_label_strings.txt
+
+To see whether BlobFromImage, which prepares each frame for the network,
+is slower on this machine than resizing and converting the frame by hand,
+the blobbench command times both on an image, with the same -swap-rb and
+-crop, and reports which is faster:
+
+	go run ./tensordrone blobbench 1000 banana.jpg
+
+The drone must be armed before it will take off: press arm, then takeoff.
+Movement commands are only sent once the drone reports it is flying, and
+it is disarmed again when it lands. The current phase is shown on screen.
+
+Before flying, check that the joystick mapping matches the controller with:
+
+	go run ./tensordrone -joytest dualshock3.json
+
+This opens only the joystick and a window showing every axis and button.
+The demo also refuses to start with a mapping file that has no sticks or
+no button for arm, takeoff, land, stop or emergency, naming the ones that
+are missing, and warns about any other bound button it does not have.
+
+Joystick mappings can be given by name rather than path. A name such as
+dualshock3 is looked for as dualshock3.json in fosdem-drone/joysticks in
+the user config directory, which is $XDG_CONFIG_HOME (usually ~/.config)
+on Linux, and is otherwise taken to be one of the mappings built into
+gobot.
+
+To line up what the drone saw with what it did, -timeline flight.csv logs
+every classification and drone command to one file, each stamped with a
+sequence number and the time since the demo started.
+
+For new pilots, -max-command 30 caps every command at 30% so that even
+full stick stays gentle. This is the beginner mode, one of the presets
+chosen with -mode that set up all of the command shaping at once:
+
+	sport      full power
+	beginner   capped at -max-command
+	cinematic  capped at 40%, with gentle expo around center stick and
+	           slowed down stick movements, for smooth video
+
+The power button (l1) cycles through the modes in flight.
+
+The drone can also be flown from the keyboard while the video window has
+focus: w, s, a and d move, i and k climb and descend, j and l turn, r arms,
+t takes off, g lands, space stops and p pauses. Emergency, which stops
+the motors in the air, has no key unless one is given to it, such as
+-keys emergency=esc. Rebind them with -keys, for example -keys up=u,down=n,
+using a single character or one of space, esc, enter, tab and backspace,
+or nothing, as in -keys stop=, to leave an action without a key.
+
+The analog triggers change how strongly the drone responds while they are
+held: squeeze r2 to boost commands up to double, and l2 to bring them down
+to a quarter for precise positioning. These are the l2 and r2 axes in the
+joystick mapping file.
+
+The buttons can be rebound with -bindings, for example to swap takeoff and
+landing:
+
+	-bindings takeoff=x,land=triangle
+
+The actions are arm (r1), stop (square), takeoff (triangle), land (x),
+emergency (circle), hull (select), power (l1), classify (right_stick),
+pause (home), bad (left), good (right), trim (up), lights (down), menu
+(start) and orbit (left_stick). Pause stops sending commands, leaving the
+drone hovering, until it is pressed again. Lights cycles the LEDs of
+drones that have them between on, blinking, oscillating and off.
+
+To tune the sticks and buttons for another controller without rebuilding,
+add a "controls" object to the joystick mapping file, or give the same
+object in a file of its own with -controls, which applies on top:
+
+	"controls": {
+		"offset": 32767,
+		"deadzones": {"left_x": 20, "left_y": 10, "right_x": 10, "right_y": 10},
+		"actions": {"stop": "square_press", "takeoff": "triangle_press", "land": "x_press"}
+	}
+
+The deadzones are how far, in raw axis values, each stick must move before
+it counts, and offset is the axis value of a stick pushed all the way.
+Anything left out keeps the value shown, and -bindings applies on top of
+the actions. Deadzones must be at least 0 and less than the offset, or the
+program stops before connecting to anything.
+
+Some drones slowly sink at zero throttle rather than hovering. With
+-hover-settle, releasing the throttle stick gives a short burst of climb
+(-settle-power for -settle-time) before returning to zero.
+
+For indoor flying, -min-altitude and -max-altitude keep the drone between
+a floor and a ceiling in meters, ignoring throttle that would go past them
+and showing a warning when they do. The minidrone does not report its
+altitude, so it is estimated from the throttle commands and -climb-rate,
+and will drift over a long flight.
+
+With -track flight.gpx, the path the drone flew is written when the demo
+exits, to look at on a map or in a GPX viewer, or as CSV for any other
+file name. It is estimated from the commands sent, using -fly-speed and
+-yaw-rate along with -climb-rate, so only gives the rough shape of a
+flight, starting from latitude and longitude 0.
+
+If the drone creeps when the sticks are left alone, the controller may be
+drifting. Fly with -log-axes axes.csv to append every axis value to a file,
+then run
+
+	go run ./tensordrone axes axes.csv
+
+to see where each axis rests and the deadzone that would hide its drift.
+
+To check that the deadzone, expo and other settings do what they should,
+-show-commands shows the pitch, roll, throttle and yaw values sent to the
+drone, from -100 to 100, with positive for forward, right, up and
+clockwise.
+
+Fast spins make the video hard to watch and blur what the classifier
+sees. -max-yaw-rate 90 caps every yaw command so that the drone turns at
+no more than about 90 degrees a second, based on the -yaw-rate it turns
+at on full yaw, as the drone does not report how fast it is turning.
+
+Commands written to the drone over BLE, such as takeoff, landing and the
+lights, that take longer than -cmd-timeout to send are abandoned so that a
+bad BLE connection cannot freeze the controls. The connection is then
+shown as degraded until a command gets through again. Movement commands,
+stop included, only change what the driver sends the drone every 50ms, so
+they are never held up.
+
+BLE commands get no reply, so the drone's state changes are watched to
+check that takeoff, landing, flat trim and emergency commands got through.
+If the drone has not reported acting on one within -ack-timeout, a warning
+is logged and shown over the video.
+
+The drone needs more command for the same response as its battery drains.
+With -battery-compensate 0.3, commands are scaled up as the battery drops
+from -battery-start percent, reaching 30% more at -battery-empty percent,
+below which the battery is shown in red as too low to compensate for. The
+scaled commands are still capped by the mode in use.
+
+The battery level the drone reports dips under motor load, so it is
+smoothed before it is shown, compensated for or warned about. Each report
+moves it by -battery-smoothing of the way, 0.2 by default; 1 turns the
+smoothing off.
+
+There is no depth sensor, but -avoid tries to keep the drone from flying
+into things. A surface being closed in on darkens and fills the bottom
+middle of the video, so when a dark area quickly spreads across it,
+forward commands are scaled down, and stopped for a second if it spreads
+far enough. This is experimental: it misses bright obstacles and flying
+into shadow sets it off.
+
+For a confined space, -fence 3 is an experimental fail-safe that keeps the
+drone within 3 meters of where it took off. There is no GPS, so where the
+drone is comes from the same dead reckoning as -track, which drifts. From
+80% of the way out, commands that would take it further out are scaled
+down, to nothing at the fence, with a warning, while commands along or
+back inside the fence still work. This holds whatever is flying the
+drone: the sticks, a demo script, or orbit, lock, follow and centering
+over the landing pad.
+
+For a precise landing with a camera looking down, add -landing-pad and put
+a dark square marker on a light floor. Land then first moves the drone,
+at up to -pad-speed, until the marker is in the middle of the frame,
+taking the top of the frame as forward, and only then lands. If the
+marker cannot be centered within -pad-timeout, it lands anyway. Pressing
+land again lands straight away, and moving the right stick stops the
+centering and leaves the drone flying.
+
+To have the drone follow someone, give it an object detection network,
+such as SSD MobileNet, with -follow-model, its -follow-config and a
+-follow-classes file of its class names, and press f in the window. The
+drone turns and climbs to keep the most confident -follow-label, person by
+default, in the middle of the frame. Moving either stick takes back
+control at once, and if the target is not seen for -follow-lost frames
+in a row the drone stops following and hovers. The follow action has no
+button until one is given to it with -bindings, such as
+-bindings emergency=select,hull=home,follow=circle.
+
+To circle an object, fly so that it is in the middle of the video and
+press orbit. The drone flies sideways at -orbit-speed while turning to
+keep the object in the middle of the frame, up to -orbit-turn. Moving the
+right stick, or the left stick sideways, takes back control at once, as
+does pressing orbit again or losing sight of the object.
+
+With -salient, only the part of each frame that stands out the most is
+classified, so that the demo looks at whatever catches the eye rather than
+the whole scene. It is outlined in yellow.
+
+For recording at a public event, -blur-faces with an OpenCV Haar cascade
+file such as haarcascade_frontalface_default.xml blurs the faces it finds
+in motion recordings and saved frames. With -window-faces the faces are
+left as they are in the window, for the pilot, and only blurred in what
+is saved.
+
+To collect training data, press v in the window to start recording a
+session and again to stop. Each session is a directory in -sessions-dir,
+named for when it started, holding every frame in frames.avi before the
+overlay goes on, an index.csv with the time, classification, flight phase,
+estimated altitude, battery and command values of each frame, and a
+manifest.json describing them (see session.go). The record action has no
+button until one is given to it with -bindings.
+
+To keep everything the drone saw, -record recordings writes every frame,
+overlay and all, to a video in the recordings directory named for when
+it started. With -snapshot-label, each time that label's score rises past
+-snapshot-score the frame is also saved there as a JPEG named for the
+label and time. Frames are written in the background, and left out of
+the video rather than holding up the display if the disk falls behind.
+
+For a summary of a long session, -timelapse summary.avi adds the video as
+shown, overlay and all, to a video every -timelapse-every, 10s by default,
+played back at 10 frames a second.
+
+To leave out the time on the ground, add -session-per-flight. Each flight
+is then recorded as a session of its own, started on takeoff and finished
+on landing, while the record action still works in between.
+
+To switch between cameras during a demo, such as the drone's and one
+watching the room, list the others with -cameras 2,4 and press c in the
+window. Only the camera in use is open. The camera action has no button
+until one is given to it with -bindings.
+
+To classify faster without making the video blurrier, -infer-scale 0.5
+classifies a half size copy of each frame while the window shows the full
+size frame, with the -salient region scaled to match.
+
+To try out a retrained model without restarting, replace the model or
+descriptions file and press m in the window to reload both, or run with
+-watch-model to reload them whenever they change. The new model is loaded
+and checked in the background and swapped in between frames, and if it
+fails to load the old one is kept. The reload action has no button until
+one is given to it with -bindings.
+
+To find out whether the computer is keeping up with the camera, give the
+rate the camera delivers at with -camera-fps 30. Frames that arrive later
+than that over the last few seconds, by half a second or more, mean the
+processing is the bottleneck, and a warning is logged and shown.
+
+As a party trick, -trigger-label banana takes off, once the drone is armed,
+when the classifier sees a banana with a score of at least -trigger-score
+for -trigger-frames classifications in a row, and -land-label lands it the
+same way, once each time the label comes into view, so that it leaves
+centering over a -landing-pad to finish. The drone must still be armed by
+hand.
+
+Ctrl-C or SIGTERM stops the control loops, the demo script and the
+buttons, and then lands the drone, and so does a panic while handling a
+camera frame. Landing is sent once however many signals arrive, and if
+landing and releasing the camera, window and network take longer than
+-shutdown-timeout, the program exits anyway.
+
+To go easy on a slow or fanless computer, -classify-every 3 only runs the
+classifier on every third frame, showing the last result in between. With
+-hot-temp 80, it also classifies less and less often while the CPU is at
+80°C or more, easing back once it is below -cool-temp. The temperature is
+read from /sys/class/thermal, or from -temp-source.
+
+For public demos, -banner credits.txt shows the lines of that file, such
+as the name of the demo, credits and attributions, over the video for
+-banner-time after starting, with the first line as a title. Any button
+or key other than emergency skips it, without doing anything else.
+
+For an unattended stand, -demo-script flies a script of timed commands
+over and over, such as takeoff, a square and a landing (see
+parseDemoScript for the commands). Any button, stick or key, pausing, an
+emergency, reaching the -fence, a degraded connection or a battery too low
+for -battery-compensate stops the script for good, leaving the drone
+hovering for the pilot or, if the connection is degraded or the battery
+low, landing it.
+
+To follow something, classify the scene until it shows what you want and
+press e in the window to lock on to it. The tracker takes over from the
+classifier, starting from the region -salient found it in or else from
+the middle of the frame, and the drone turns and climbs to keep it in the
+middle of the frame, up to -lock-turn and -lock-climb. The right stick
+still flies forwards, backwards and sideways. Moving the left stick, or
+pressing e again, lets go. The lock action has no button until one is
+given to it with -bindings.
+
+The menu button opens a menu over the video for changing the stick
+deadzone, the expo and command cap of the mode in use, the confidence
+needed to announce a classification and the profile, without a keyboard. Pick a setting
+with up and down and change it with left and right. While the menu is
+open the d-pad only drives the menu. Choose resume, or press menu again,
+to close it.
+
+To keep the tuning for each drone and scene, save the tuning flags as a
+named profile, and load it again with -profile:
+
+	go run ./tensordrone -max-command 50 -guard-altitude 0.5 -save-profile indoor-mambo
+	go run ./tensordrone -profile indoor-mambo "Mambo_1234" dualshock3.json 0 tensorflow_inception_graph.pb imagenet_comp_graph_label_strings.txt
+
+Profiles are kept in fosdem-drone/profiles in the user config directory.
+Flags given on the command line take precedence over the profile. While
+the drone is on the ground, the menu can switch to another profile.
+
+To send fewer commands over a busy BLE connection, -max-rate 100 sends at
+most 100 movement commands a second, dropping repeats of a command that
+has not changed. The rate is shown on screen, marked limited while
+commands are being dropped.
+
+Near the ground the props can catch, so -guard-altitude 0.5 scales down
+forward, backward and sideways commands below half a meter, from
+-guard-min of full power on the ground up to full power at 0.5 meters.
+
+Add -bars 5 to draw the five most probable classifications as a bar graph,
+each bar as long as how probable it is.
+
+To save CPU, -on-demand only runs the classifier when the classify button
+is pressed, and keeps that result on screen until it is pressed again.
+
+To collect data for improving a model, press bad when the classifier gets a
+frame wrong or is unsure, and good when it is right. The frame is saved to
+-dataset-dir/bad or -dataset-dir/good along with the top -top predictions
+for it, and a JSON file that also records the state of the drone and the
+tuning flags in use.
+
+To hear the demo, add -audio. It plays takeoff.wav and land.wav from the
+-audio-dir directory on those events, and a WAV file named after each label
+(such as banana.wav) when the classification changes to it.
+
+For a security drone, add -motion. Moving regions are outlined, and video
+is recorded into -motion-dir while they cover more than -motion-area pixels.
+
+Add -trail 20 to draw a fading trail of the last 20 positions of the
+largest moving object found by -motion.
+
+Add -flow to draw the optical flow, how each part of the scene moves from
+one frame to the next, as arrows over the video.
+
+Add -qr to outline and decode QR codes in the video. This and the other
+extras are FrameProcessors (see processor.go), and your own can be added
+in a new file that calls RegisterFrameProcessor from an init function.
+
+With -publish, each classification is also published as a gobot event on
+Classifications (see processor.go), so that other devices and robots can
+react to what the camera sees.
+
+For VJ and music software at live events, -osc localhost:9000 sends an
+OSC message to that address whenever the classification changes, as
+/classification with the label and its score.
+
+For a live web dashboard, -ws :8080 pushes each classification change to
+WebSocket clients of ws://localhost:8080/classifications as JSON with the
+label, score and time. http://localhost:8080/ is a simple page showing
+them.
+
+Add -history 10 to list the last ten classifications, with the time each
+was seen, in a sidebar to the right of the video.
+
+For an audience that speaks another language, -lang fr shows labels as
+translated in translations/fr.txt (see -lang-dir). Each line of that file
+is a label from the descriptions file, a tab, and its translation. Labels
+without a translation are shown as they are.
+
+Cameras that deliver portrait frames, such as phones, can be turned to
+landscape with -auto-orient before the frames are classified. They are
+rotated clockwise, or counterclockwise with -orient-ccw.
+
+The overlay text is green by default. If that is hard to read against the
+scene, pick another color with -text-color as RGB hex, such as ffffff for
+white, or use -text-color auto to draw each line in black or white,
+whichever stands out from the video behind it. -text-thickness sets how
+bold the text is.
+
+When the screen faces the audience, -mirror-display mirrors the video so
+that movements look natural to them. The classifier still sees the frames
+the right way round, and the overlay text is not mirrored.
+
+Wide angle lenses bend straight lines, which throws off both the overlay
+and the classifier. To calibrate one, print a chessboard, run
+
+	go run ./tensordrone calibrate 0 9x6 lens.json
+
+with the camera ID and the number of inner corners of the chessboard, and
+press space to add a view each time the corners are found, moving the
+board around and tilting it between views. Press enter once there are at
+least ten to write the calibration to lens.json, then fly with
+-calibration lens.json to undistort every frame before it is used.
+
+To practise without a drone, or try out a change to the controls, add -sim.
+The drone ID is then ignored, and a simulated drone flies from the same
+commands, moving at -fly-speed, -yaw-rate and -climb-rate with a little
+inertia. It is shown from above in a window of its own, with a grid a
+meter apart, an arrow for its heading, a trail of where it has been and
+a bar for its altitude.
+
+If only a few classes matter, such as people and pets, list them in a file
+one per line, as they are in the descriptions file, and add -label-subset
+pets.txt. The best of them is reported, and other when the best match is
+something else. A tab and a name after a label reports it under that
+name, so that every dog breed can be reported as dog.
+
+If the video window is closed by mistake during a demo, it is opened
+again, up to three times. With -reopen-window=false the demo carries on
+without it instead, still flying, classifying and recording.
+
+The overlay is drawn in layers, each of which can be shown or hidden on
+its own: classification, regions (the outlines from -salient and -avoid),
+status, battery, commands and history. Keys 1 to 6 toggle them in that
+order, and the layer-status and similar actions can be bound to buttons.
+-hide-layers status,battery starts with them hidden. Warnings are always
+shown.
+
+For an unattended booth, -metrics :9100 serves Prometheus metrics at
+/metrics: frames processed, an inference latency histogram, classifications
+by label, commands sent by name, the battery level, whether the drone
+connection is degraded and whether it is flying.
+
+To line up the camera on the drone, add -grid to draw a rule-of-thirds grid
+and a level line over the video.
+
+For teaching, -student student.json adds a second controller for a student
+who flies forward, backward and sideways with its right stick, while the
+first controller keeps throttle, yaw and all of the buttons so that an
+instructor can take over altitude at any time. The student mapping
+file must name its right stick axes right_x and right_y.
+
+Before the first takeoff, put the drone on a level surface and press trim
+to flat trim it, which calibrates what the drone treats as level. Takeoff
+is refused until this is done, unless -require-trim=false. Trim only works
+while the drone is on the ground.
+
+To try out the controls without a joystick, -replay script.txt sends the
+joystick events in a script instead, each line being the time to send it,
+the event and for an axis its value, such as "3s right_y -20000". See
+replay.go for the format. The joystick file argument is then ignored.
 
 NOTE: sudo is required to use BLE in Linux
 */
@@ -19,9 +501,13 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
+	"log"
 	"os"
 	"strconv"
 	"sync/atomic"
@@ -42,174 +528,1521 @@ type pair struct {
 
 var leftX, leftY, rightX, rightY atomic.Value
 
-const offset = 32767.0
+// classifyNow is set to 1 when the classify button asks for the next frame
+// to be classified in on demand mode.
+var classifyNow int32
+
+// paused is whether the control loops are suspended, leaving the drone
+// hovering until they are resumed.
+var paused atomic.Value
+
+// hullOn is whether the hull is fitted, and so whether to tell the drone
+// to fly with hull protection on when it takes off.
+var hullOn atomic.Value
+
+var (
+	backend       = flag.String("backend", "default", "DNN backend: default, halide, openvino, opencv, vulkan or cuda")
+	target        = flag.String("target", "cpu", "DNN target: cpu, fp32, fp16, vpu, vulkan, fpga, cuda or cudafp16")
+	hull          = flag.Bool("hull", true, "the drone has its hull fitted, toggle with the hull button")
+	top           = flag.Int("top", 5, "how many classifications the classify command prints")
+	displayFPS    = flag.Int("display-fps", 30, "how many times a second the window is redrawn")
+	onDemand      = flag.Bool("on-demand", false, "only classify a frame when the classify button is pressed")
+	mirrorDisplay = flag.Bool("mirror-display", false, "mirror the video shown, but not the frames classified, for a screen facing the audience")
+	showCommands  = flag.Bool("show-commands", false, "show the pitch, roll, throttle and yaw command values sent to the drone, toggle with the layer-commands action")
+	listLabels    = flag.String("list-labels", "", "print every label in this descriptions file with its index, then exit")
+)
 
 func main() {
 	// parse args
-	if len(os.Args) < 6 {
-		fmt.Println("How to run:\n\ttensordrone [drone ID] [joystick JSON file] [cameraid] [modelfile] [descriptionsfile]")
+	flag.Parse()
+	given := commandLineFlags()
+	if *profileName != "" {
+		if err := loadProfile(*profileName, given); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *saveProfile != "" {
+		path, err := writeProfile(*saveProfile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("saved profile to", path)
+		return
+	}
+
+	if flag.Arg(0) == "classify" {
+		if err := classifyImage(flag.Args()[1:], *top, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *listLabels != "" {
+		descriptions, err := readDescriptions(*listLabels)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for i, d := range descriptions {
+			fmt.Printf("%d\t%v\n", i, d)
+		}
 		return
 	}
 
-	droneID := os.Args[1]
-	joystickFile := os.Args[2]
-	deviceID, _ := strconv.Atoi(os.Args[3])
-	model := os.Args[4]
-	descriptions, _ := readDescriptions(os.Args[5])
+	if flag.Arg(0) == "calibrate" {
+		if err := calibrateLens(flag.Args()[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	joystickAdaptor := joystick.NewAdaptor()
-	stick := joystick.NewDriver(joystickAdaptor, joystickFile)
+	if flag.Arg(0) == "matcheck" {
+		if err := checkMats(flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	droneAdaptor := ble.NewClientAdaptor(droneID)
-	drone := minidrone.NewDriver(droneAdaptor)
+	if flag.Arg(0) == "blobbench" {
+		if err := benchBlobs(flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	window := opencv.NewWindowDriver()
-	camera := opencv.NewCameraDriver(deviceID)
+	if flag.Arg(0) == "axes" {
+		if flag.NArg() < 2 {
+			fmt.Println("How to run:\n\ttensordrone axes [axis log file]")
+			os.Exit(1)
+		}
+		if err := reportAxes(flag.Arg(1), os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// open Tensorflow DNN classifier
-	net := gocv.ReadNetFromTensorflow(model)
-	defer net.Close()
+	if *joyTest {
+		if flag.NArg() < 1 {
+			fmt.Println("How to run:\n\ttensordrone -joytest [joystick JSON file]")
+			os.Exit(1)
+		}
+		runJoystickTest(resolveMapping(flag.Arg(0)))
+		return
+	}
+
+	if *displayFPS < 1 {
+		fmt.Println("-display-fps must be at least 1")
+		os.Exit(1)
+	}
+
+	if *maxCommand < 0 || *maxCommand > 100 {
+		fmt.Println("-max-command must be between 0 and 100")
+		os.Exit(1)
+	}
+	if err := setupOverlayStyle(*textColor, *textThickness); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	hide := *hideLayers
+	if !*showCommands {
+		hide += ",commands"
+	}
+	if err := setupLayers(hide); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := setupPresets(*modeFlag, *maxCommand); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *inferScale <= 0 || *inferScale > 1 {
+		fmt.Println("-infer-scale must be more than 0 and at most 1")
+		os.Exit(1)
+	}
+	if *classifyEveryN < 1 {
+		fmt.Println("-classify-every must be at least 1")
+		os.Exit(1)
+	}
+	classifyEvery = int32(*classifyEveryN)
+	if *hotTemp > 0 && *tempSource == "" {
+		source, err := findTempSource()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		*tempSource = source
+	}
+
+	keys, err := parseKeys(*keysFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if flag.NArg() < 5 {
+		fmt.Println("How to run:\n\ttensordrone [flags] [drone ID] [joystick JSON file] [cameraid] [modelfile] [descriptionsfile]")
+		fmt.Println("\ttensordrone [flags] classify [imagefile] [modelfile] [descriptionsfile]")
+		fmt.Println("\ttensordrone axes [axis log file]")
+		fmt.Println("\ttensordrone [flags] matcheck [frames] [modelfile] [descriptionsfile]")
+		fmt.Println("\ttensordrone [flags] blobbench [iterations] [imagefile]")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	droneID := flag.Arg(0)
+	joystickFile := resolveMapping(flag.Arg(1))
+	deviceID, _ := strconv.Atoi(flag.Arg(2))
+	model := flag.Arg(3)
+
+	// catch the common mistakes before any hardware is touched
+	type required struct{ what, path string }
+	files := []required{
+		{"model", model},
+		{"descriptions", flag.Arg(4)},
+	}
+	if *replayFile == "" && !builtinJoystick(joystickFile) {
+		files = append(files, required{"joystick", joystickFile})
+	}
+	if *studentFile != "" {
+		*studentFile = resolveMapping(*studentFile)
+		files = append(files, required{"student joystick", *studentFile})
+	}
+	for _, f := range files {
+		if err := checkFile(f.what, f.path); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// and controls that would not fly, reading them before the buttons so
+	// that -bindings applies on top of them
+	controlsFrom := joystickFile
+	if *replayFile != "" || builtinJoystick(joystickFile) {
+		controlsFrom = ""
+	}
+	if controls, err = readControls(controlsFrom, *controlsFile); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	buttons, err := parseBindings(controls.bindings(), *bindingsFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// and a mapping that would leave the drone without some of its controls
+	if *replayFile == "" && !builtinJoystick(joystickFile) {
+		axes := []string{joystick.LeftX, joystick.LeftY, joystick.RightX, joystick.RightY}
+		if *studentFile != "" {
+			axes = axes[:2]
+		}
+		if err := checkMapping(joystickFile, axes, buttons); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *studentFile != "" {
+		if err := checkMapping(*studentFile, []string{joystick.RightX, joystick.RightY}, nil); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	descriptions, err := readDescriptions(flag.Arg(4))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var connections []gobot.Connection
+	var devices []gobot.Device
+	var stick joystickSource
+
+	// in two-pilot mode the right stick axes come from the student instead
+	var pitchRoll gobot.Eventer
+	if *replayFile != "" {
+		script, err := readScript(*replayFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		stick = newScriptedStick(script)
+		devices = append(devices, stick)
+		pitchRoll = stick
+	} else {
+		joystickAdaptor := joystick.NewAdaptor()
+		stick = joystick.NewDriver(joystickAdaptor, joystickFile)
+		connections = append(connections, joystickAdaptor)
+		devices = append(devices, stick)
+		pitchRoll = stick
+		if *studentFile != "" {
+			student := newStudentStick(joystickAdaptor, *studentFile)
+			devices = append(devices, student)
+			pitchRoll = student
+		}
+	}
+
+	var demo *scriptRunner
+	if *demoScriptFile != "" {
+		steps, err := readDemoScript(*demoScriptFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		demo = newScriptRunner(steps)
+	}
+
+	// with -sim there is no drone to connect to, and the simulated one is
+	// shown in a window of its own
+	var drone flyingDrone
+	var sim *simDrone
+	var simWindow *videoWindow
+	if *simulate {
+		sim = newSimDrone()
+		defer sim.Close()
+		simWindow = newVideoWindow("Simulator")
+		drone = sim
+		devices = append(devices, simWindow)
+	} else {
+		droneAdaptor := ble.NewClientAdaptor(droneID)
+		drone = minidrone.NewDriver(droneAdaptor)
+		connections = append(connections, droneAdaptor)
+	}
+
+	events, err := openTimeline(*timelinePath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer events.Close()
+
+	osc, err := newOSCSender(*oscAddr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer osc.Close()
+
+	dashboard, err := newWSServer(*wsAddr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	axes, err := openAxisLog(*logAxesPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer axes.Close()
+	alt := newAltimeter(*climbRate)
+	prom, err := newMetrics(*metricsAddr)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	track := newFlightTrack(*trackPath, *flySpeed, *yawRate)
+	fence := newGeofence(*fenceRadius, track)
+	pilot := newPilot(drone, events, prom, alt, fence, *cmdTimeout, *maxRate)
+	halt := newShutdown(drone, *shutdownTimeout)
+	acks := newAckTracker(*ackTimeout, events)
+
+	window := newVideoWindow("Window")
+	var camera frameSource = opencv.NewCameraDriver(deviceID)
+	var cameras *cameraSwitcher
+	if *extraCameras != "" {
+		ids, err := parseCameras(*extraCameras)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		cameras = newCameraSwitcher(append([]int{deviceID}, ids...))
+		camera = cameras
+	}
+
+	var labels translations
+	if *lang != "" {
+		labels, err = readTranslations(*langDir, *lang)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// open DNN classifier
+	cls, err := newClassifier(model, descriptions, *backend, *target)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// cls is replaced when the model is reloaded, so close whichever is last
+	halt.onClose(func() error { return cls.Close() })
+	if err := cls.check(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	reloader := newModelReloader(model, flag.Arg(4), *backend, *target)
+
+	display := newFrameBuffer()
+	defer display.Close()
+
+	recent := newHistory(*historyLength)
+	defer recent.Close()
+
+	var saliency *saliencyDetector
+	if *salient {
+		saliency = newSaliencyDetector()
+		defer saliency.Close()
+	}
+
+	lens, err := newUndistorter(*calibrationFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer lens.Close()
+
+	// frames are scaled down into this one by -infer-scale
+	small := gocv.NewMat()
+	defer small.Close()
+
+	intro, err := readBanner(*bannerFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// portrait frames are turned into this one by -auto-orient
+	rotated := gocv.NewMat()
+	defer rotated.Close()
+
+	stats := newSessionStats()
+	drift := newDriftChecker(*cameraFPS)
+	triggers := newTriggerWatcher(*triggerLabel, *landLabel, *triggerScore, *triggerFrames)
+	session := newSessionRecorder(*sessionsDir)
+	defer session.Close()
+	lapse := newTimelapse(*timelapsePath, *timelapseEvery)
+	defer lapse.Close()
+	rec, err := newRecorder(*recordDir, *snapshotLabel, *snapshotScore)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer rec.Close()
+	phase := &flightPhase{}
+	trim := &flatTrim{}
+	prom.gauge("tensordrone_battery_percent", "Battery level last reported by the drone, -1 until it reports one.", func() float64 {
+		return float64(atomic.LoadInt32(&batteryLevel))
+	})
+	prom.gauge("tensordrone_connection_degraded", "1 while commands to the drone are timing out.", func() float64 {
+		if pilot.isDegraded() {
+			return 1
+		}
+		return 0
+	})
+	prom.gauge("tensordrone_flying", "1 while the drone is flying.", func() float64 {
+		if phase.is(Flying) {
+			return 1
+		}
+		return 0
+	})
+	lights := &lightShow{}
+	items := []menuItem{
+		{name: "deadzone", format: "%.0f%%", step: 1, min: 0, max: 50,
+			get: func() float64 { return deadzone.Load().(float64) },
+			set: func(v float64) { deadzone.Store(v) }},
+		{name: "expo", format: "%.1f", step: 0.1, min: 0, max: 1,
+			get: func() float64 { return shaping().expo },
+			set: func(v float64) { tunePreset(func(p *preset) { p.expo = v }) }},
+		{name: "confidence", format: "%.2f", step: 0.05, min: 0, max: 1,
+			get: func() float64 { return confidence.Load().(float64) },
+			set: func(v float64) { confidence.Store(v) }},
+		{name: "max command", format: "%.0f%%", step: 5, min: 5, max: 100,
+			get: func() float64 { return float64(shaping().limit) },
+			set: func(v float64) { tunePreset(func(p *preset) { p.limit = int(v) }) }},
+	}
+
+	// switching profile sets the tuning flags again, so pick up the ones
+	// that are copied when the demo starts
+	names, current := profiles(), -1
+	for i, name := range names {
+		if name == *profileName {
+			current = i
+		}
+	}
+	if len(names) > 0 {
+		items = append(items, menuItem{name: "profile", step: 1, min: 0, max: float64(len(names) - 1),
+			get: func() float64 { return float64(current) },
+			set: func(v float64) {
+				i := int(v)
+				if i == current || (!phase.is(Disarmed) && !phase.is(Armed)) {
+					return
+				}
+				if err := loadProfile(names[i], given); err != nil {
+					log.Println(err)
+					return
+				}
+				current = i
+				deadzone.Store(*deadzonePercent)
+				confidence.Store(*audioConfidence)
+				hullOn.Store(*hull)
+				if err := setupPresets(*modeFlag, *maxCommand); err != nil {
+					log.Println(err)
+				}
+				if err := setupOverlayStyle(*textColor, *textThickness); err != nil {
+					log.Println(err)
+				}
+			},
+			show: func() string {
+				if current < 0 {
+					return "none"
+				}
+				return names[current]
+			}})
+	}
+	settings := newMenu(items)
+
+	var sounds *soundPlayer
+	if *audio {
+		sounds = newSoundPlayer(*audioDir)
+	}
+
+	faces, err := newFaceBlur(*blurFaces)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer faces.Close()
+
+	// track objects first, before anything is drawn over the frame
+	tracker := &objectTracker{}
+	defer tracker.stop()
+	release := registerProcessors(tracker, faces)
+	defer release()
+
+	// following needs a detection network as well as the classifier
+	var follow *followProcessor
+	if *followModel != "" {
+		detector, err := newObjectDetector(*followModel, *followConfig, *followClasses)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		follow = newFollowProcessor(detector)
+		defer follow.Close()
+		RegisterFrameProcessor(follow)
+	}
 
 	work := func() {
+		intro.start(time.Now(), *bannerTime)
 		leftX.Store(float64(0.0))
 		leftY.Store(float64(0.0))
 		rightX.Store(float64(0.0))
 		rightY.Store(float64(0.0))
+		l2.Store(-controls.Offset)
+		r2.Store(-controls.Offset)
+		hullOn.Store(*hull)
+		paused.Store(false)
+		deadzone.Store(*deadzonePercent)
+		confidence.Store(*audioConfidence)
+		altitudeLimit.Store("")
+		guardFactor.Store(float64(1))
 
+		var desc string
+		var maxVal float32
+		var classified bool
+		var ranked []prediction
+
+		addLayer("classification", false, func(f *layerFrame) {
+			drawClassification(f.overlay, labels, f.result, f.ranked)
+		})
+		addLayer("regions", true, func(f *layerFrame) {
+			if *avoidObstacles {
+				drawObstacle(f.img)
+			}
+			if saliency != nil && !f.subject.Empty() {
+				gocv.Rectangle(f.img, f.subject, color.RGBA{255, 255, 0, 0}, 2)
+			}
+			if *landingPad {
+				drawPad(f.img)
+			}
+			drawFollow(f.img)
+		})
+		addLayer("status", false, func(f *layerFrame) {
+			overlay := f.overlay
+			hullStatus := "hull: off"
+			if hullOn.Load().(bool) {
+				hullStatus = "hull: on"
+			}
+			overlay.text(topRight, "phase: "+phase.current().String())
+			overlay.text(topRight, powerMode())
+			overlay.text(topRight, hullStatus)
+			if isOrbiting() {
+				overlay.text(topRight, "orbiting")
+			}
+			if isLocked() {
+				overlay.text(topRight, "locked on: "+labels.translate(lockedOn()))
+			}
+			if isCentering() {
+				overlay.text(topRight, "centering over the landing pad")
+			}
+			if msg := followStatus(); msg != "" {
+				overlay.text(topRight, msg)
+			}
+			if demo.isRunning() {
+				overlay.text(topRight, "demo script")
+			}
+			if cameras != nil {
+				overlay.text(topRight, cameras.status())
+			}
+			if msg := session.status(); msg != "" {
+				overlay.textColor(topRight, msg, color.RGBA{255, 0, 0, 0})
+			}
+			if msg := throttleStatus(); msg != "" {
+				overlay.textColor(topRight, msg, color.RGBA{255, 255, 0, 0})
+			}
+			if msg := lights.status(); msg != "" {
+				overlay.text(topRight, msg)
+			}
+			if *guardAltitude > 0 {
+				overlay.text(topRight, fmt.Sprintf("prop guard: %.0f%%", guardFactor.Load().(float64)*100))
+			}
+			if *maxRate > 0 {
+				perSecond, limiting := pilot.commandRate()
+				msg := fmt.Sprintf("commands: %d/s", perSecond)
+				if limiting {
+					overlay.textColor(topRight, msg+" (limited)", color.RGBA{255, 255, 0, 0})
+				} else {
+					overlay.text(topRight, msg)
+				}
+			}
+		})
+		addLayer("battery", false, func(f *layerFrame) {
+			if msg, low := batteryStatus(); low {
+				f.overlay.textColor(topRight, msg, color.RGBA{255, 0, 0, 0})
+			} else if msg != "" {
+				f.overlay.text(topRight, msg)
+			}
+		})
+		addLayer("commands", false, func(f *layerFrame) {
+			c := pilot.commands()
+			f.overlay.text(bottomRight, fmt.Sprintf("yaw %d", c[yawAxis]))
+			f.overlay.text(bottomRight, fmt.Sprintf("throttle %d", c[throttleAxis]))
+			f.overlay.text(bottomRight, fmt.Sprintf("roll %d", c[rollAxis]))
+			f.overlay.text(bottomRight, fmt.Sprintf("pitch %d", c[pitchAxis]))
+		})
+
+		// warnings are always shown, whichever layers are hidden
+		drawWarnings := func(overlay *overlayLayout) {
+			if msg := acks.status(time.Now()); msg != "" {
+				overlay.textColor(bottomLeft, msg, color.RGBA{255, 0, 0, 0})
+			}
+			if msg := obstacleStatus(); msg != "" {
+				overlay.textColor(bottomLeft, msg, color.RGBA{255, 0, 0, 0})
+			}
+			if msg, at := fence.status(); at {
+				overlay.textColor(bottomLeft, msg, color.RGBA{255, 0, 0, 0})
+			} else if msg != "" {
+				overlay.textColor(bottomLeft, msg, color.RGBA{255, 255, 0, 0})
+			}
+			if msg := drift.status(); msg != "" {
+				overlay.textColor(bottomLeft, msg, color.RGBA{255, 255, 0, 0})
+			}
+			if pilot.isDegraded() {
+				overlay.textColor(bottomLeft, "warning: drone connection degraded", color.RGBA{255, 0, 0, 0})
+			}
+			if paused.Load().(bool) {
+				overlay.textColor(bottomLeft, "PAUSED", color.RGBA{255, 255, 0, 0})
+			}
+			if msg := trim.status(time.Now()); msg != "" {
+				overlay.textColor(bottomLeft, msg, color.RGBA{255, 255, 0, 0})
+			}
+			if limit := altitudeLimit.Load().(string); limit != "" {
+				overlay.textColor(bottomLeft, "warning: "+limit, color.RGBA{255, 0, 0, 0})
+			}
+		}
+
+		warnedEmpty := false
+		frames := 0
+		var subject image.Rectangle
 		camera.On(opencv.Frame, func(data interface{}) {
-			img := data.(gocv.Mat)
+			if !halt.frame() {
+				return
+			}
+			defer halt.frameDone()
+			defer halt.recover("camera frame")
 
-			// convert image Mat to 224x244 blob that the classifier can analyze
-			blob := gocv.BlobFromImage(img, 1.0, image.Pt(224, 244), gocv.NewScalar(0, 0, 0, 0), true, false)
+			// some cameras deliver empty frames while they warm up
+			frame := data.(gocv.Mat)
+			if frame.Empty() {
+				if !warnedEmpty {
+					log.Println("skipping empty frames from the camera")
+					warnedEmpty = true
+				}
+				return
+			}
+			img := orient(lens.undistort(frame), &rotated)
+			stats.frame()
+			prom.frame()
+			drift.frame(time.Now())
+			cls = reloader.swap(cls)
 
-			// feed the blob into the Tensorflow classifier network
-			net.SetInput(blob, "input")
+			// in on demand mode, only run the classifier when asked to and
+			// keep showing that result until the next time, and otherwise
+			// keep showing it on the frames that -classify-every skips
+			frames++
+			due := frames%int(atomic.LoadInt32(&classifyEvery)) == 0
+			if !*onDemand && due {
+				classified = false
+			}
+			if (!*onDemand && due) || atomic.CompareAndSwapInt32(&classifyNow, 1, 0) {
+				start := time.Now()
 
-			// run a forward pass thru the network
-			prob := net.Forward("softmax2")
+				// with -infer-scale, classify a smaller copy of the frame
+				look := img
+				if *inferScale < 1 {
+					gocv.Resize(img, &small, image.Point{}, *inferScale, *inferScale, gocv.InterpolationArea)
+					look = small
+				}
 
-			// reshape the results into a 1x1000 matrix
-			probMat := prob.Reshape(1, 1)
+				// with -salient, only look at what stands out the most,
+				// keeping where it is in display frame coordinates
+				if saliency != nil {
+					r := saliency.region(look)
+					subject = scaleRect(r, 1 / *inferScale).Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+					look = look.Region(r)
+				}
+
+				var d string
+				var v float32
+				var err error
+				if *bars > 0 {
+					// the top predictions for the bar graph, best first
+					ranked, err = cls.predict(look, *bars)
+					if err == nil && len(ranked) > 0 {
+						d, v = ranked[0].label, ranked[0].score
+					}
+				} else {
+					d, v, err = cls.classify(look)
+				}
+				if saliency != nil {
+					look.Close()
+				}
+				if err == nil {
+					stats.classified(d, time.Since(start))
+					prom.classified(d, time.Since(start))
+					events.record("classification", "%v %.4f", d, v)
+					sounds.announce(d, v, float32(confidence.Load().(float64)))
+					publishClassification(ClassificationResult{Label: d, Score: v, OK: true})
+					osc.send(d, v)
+					dashboard.send(d, v)
+					recent.add(d, time.Now())
+					triggers.observe(d, v)
+					desc, maxVal, classified = d, v, true
+					detected(d, subject)
+				}
+			}
 
-			// determine the most probable classification, which will be max value
-			_, maxVal, _, maxLoc := gocv.MinMaxLoc(probMat)
+			// blur faces once the classifier has seen the frame, but before
+			// it can be saved, leaving them in the window with -window-faces
+			faces.detect(img)
+			if !*windowFaces {
+				faces.blur(&img)
+			}
+
+			state := droneState{
+				Battery:  int(atomic.LoadInt32(&batteryLevel)),
+				Altitude: alt.height(),
+				Phase:    phase.current().String(),
+				Mode:     shaping().name,
+			}
+			session.record(img, sessionSample{label: desc, score: maxVal, state: state, commands: pilot.commands()}, time.Now())
+
+			// save the unmarked frame for the dataset if asked to
+			select {
+			case kind := <-exampleRequests:
+				preds, err := cls.predictFrame(img, *top)
+				if err == nil {
+					out, closeIt := faces.blurred(img)
+					err = saveExample(*datasetDir, kind, out, preds, state)
+					if closeIt {
+						out.Close()
+					}
+				}
+				if err != nil {
+					log.Println("dataset:", err)
+				}
+			default:
+			}
+
+			result := ClassificationResult{Label: desc, Score: maxVal, OK: classified}
+			processFrame(img, result)
+			f := &layerFrame{img: &img, result: result, ranked: ranked, subject: subject}
+			drawLayers(f, true)
+
+			// mirror once everything that needs the true frame has seen it,
+			// but before the text goes on so that it can still be read
+			if *mirrorDisplay {
+				gocv.Flip(img, &img, 1)
+			}
+
+			f.overlay = newOverlayLayout(&img)
+			drawLayers(f, false)
+			drawWarnings(f.overlay)
+
+			settings.draw(&img)
+			if intro.showing(time.Now()) {
+				intro.draw(&img)
+			}
+
+			// record the frame as annotated, before the history widens it
+			if rec != nil {
+				out, closeIt := faces.blurred(img)
+				rec.capture(out, desc, maxVal, classified, time.Now())
+				if closeIt {
+					out.Close()
+				}
+			}
+
+			// the history widens the frame, so it goes on last
+			shown := img
+			if layerShown("history") {
+				shown = recent.compose(img, labels)
+			}
+			lapse.capture(shown, time.Now())
+			display.put(shown)
+		})
+
+		drone.On(minidrone.Takeoff, func(data interface{}) {
+			stats.tookOff()
+			session.tookOff(time.Now())
+			fence.tookOff()
+			sounds.play("takeoff")
+		})
+
+		drone.On(minidrone.Hovering, func(data interface{}) {
+			if phase.is(TakingOff) {
+				alt.set(takeoffHeight, time.Now())
+			}
+			phase.to(Flying)
+		})
 
-			// display classification based on position in the descriptions file
-			desc := "Unknown"
-			if maxLoc.X < 1000 {
-				desc = descriptions[maxLoc.X]
+		drone.On(minidrone.Flying, func(data interface{}) {
+			if phase.is(TakingOff) {
+				alt.set(takeoffHeight, time.Now())
 			}
-			status := fmt.Sprintf("description: %v, maxVal: %v\n", desc, maxVal)
-			gocv.PutText(img, status, image.Pt(10, 20), gocv.FontHersheyPlain, 1.2, color.RGBA{0, 255, 0, 0}, 2)
+			phase.to(Flying)
+		})
+
+		drone.On(minidrone.Landed, func(data interface{}) {
+			stopOrbit(tracker)
+			stopLock(tracker)
+			stopFollow()
+			alt.set(0, time.Now())
+			stats.landed()
+			session.landed()
+			sounds.play("land")
+			phase.to(Disarmed)
+		})
+
+		drone.On(minidrone.Emergency, func(data interface{}) {
+			demo.abort("emergency")
+			stopCentering()
+			stopFollow()
+			phase.to(Emergency)
+		})
+
+		// the state changes that show the drone acted on a command
+		for _, event := range []string{minidrone.Takeoff, minidrone.Hovering, minidrone.Flying,
+			minidrone.Landing, minidrone.Landed, minidrone.Emergency, minidrone.FlatTrimChange} {
+			event := event
+			drone.On(event, func(data interface{}) {
+				acks.confirm(event, time.Now())
+			})
+		}
+		halt.every(250*time.Millisecond, func() {
+			acks.check(time.Now())
+		})
+
+		if *watchModel {
+			halt.every(2*time.Second, reloader.watch)
+		}
+
+		if track != nil {
+			halt.every(100*time.Millisecond, func() {
+				if phase.is(Disarmed) || phase.is(Armed) || phase.is(Emergency) {
+					track.pause()
+					return
+				}
+				// paused, the drone hovers whatever was last sent
+				commands := pilot.commands()
+				if paused.Load().(bool) {
+					commands = [4]int{}
+				}
+				track.update(commands, alt.height(), time.Now())
 
-			blob.Close()
-			prob.Close()
-			probMat.Close()
+				// held commands, which only a demo script leaves, are
+				// limited afresh as the drone moves
+				if phase.is(Flying) && demo.isRunning() && !paused.Load().(bool) {
+					pilot.holdFence()
+				}
+			})
+		}
 
-			window.ShowImage(img)
-			window.WaitKey(1)
+		drone.On(minidrone.Battery, func(data interface{}) {
+			batteryReported(data.(uint8))
 		})
 
-		stick.On(joystick.SquarePress, func(data interface{}) {
-			drone.Stop()
+		drone.On(minidrone.FlatTrimChange, func(data interface{}) {
+			trim.changed(time.Now())
 		})
 
-		stick.On(joystick.TrianglePress, func(data interface{}) {
-			drone.HullProtection(true)
-			drone.TakeOff()
+		land := func() {
+			if !phase.is(TakingOff) && !phase.is(Flying) {
+				return
+			}
+			phase.to(Landing)
+			pilot.Land()
+			acks.expect("land", time.Now(), minidrone.Landing, minidrone.Landed)
+		}
+
+		actions := map[string]func(){
+			"arm": func() {
+				switch phase.current() {
+				case Disarmed:
+					phase.to(Armed)
+				case Armed, Emergency:
+					phase.to(Disarmed)
+				}
+			},
+			"stop": func() {
+				if phase.is(Flying) {
+					pilot.Stop()
+				}
+			},
+			"takeoff": func() {
+				if !trim.ready() {
+					return
+				}
+				if err := phase.to(TakingOff); err != nil {
+					return
+				}
+				pilot.HullProtection(hullOn.Load().(bool))
+				pilot.TakeOff()
+				acks.expect("takeoff", time.Now(), minidrone.Takeoff, minidrone.Hovering, minidrone.Flying)
+			},
+			"land": func() {
+				// with -landing-pad, center over the pad first, and land
+				// from the right stick loop once over it
+				if *landingPad && phase.is(Flying) && !isCentering() {
+					stopOrbit(tracker)
+					stopLock(tracker)
+					stopFollow()
+					startCentering(time.Now())
+					return
+				}
+				stopCentering()
+				land()
+			},
+			"emergency": func() {
+				phase.to(Emergency)
+				pilot.Emergency()
+				acks.expect("emergency", time.Now(), minidrone.Emergency, minidrone.Landed)
+			},
+			"hull": func() {
+				hullOn.Store(!hullOn.Load().(bool))
+			},
+			"power": func() {
+				nextPreset()
+			},
+			"pause": func() {
+				pause := !paused.Load().(bool)
+				paused.Store(pause)
+				if pause && phase.is(Flying) {
+					// hover rather than carry on with the last command
+					pilot.Stop()
+				}
+			},
+			"bad": func() {
+				requestExample("bad")
+			},
+			"good": func() {
+				requestExample("good")
+			},
+			"classify": func() {
+				atomic.StoreInt32(&classifyNow, 1)
+			},
+			"orbit": func() {
+				if isOrbiting() {
+					stopOrbit(tracker)
+				} else if phase.is(Flying) {
+					stopLock(tracker)
+					stopFollow()
+					startOrbit(tracker)
+				}
+			},
+			"lock": func() {
+				if isLocked() {
+					stopLock(tracker)
+				} else if phase.is(Flying) {
+					stopOrbit(tracker)
+					stopFollow()
+					startLock(tracker)
+				}
+			},
+			"follow": func() {
+				switch {
+				case isFollowing():
+					stopFollow()
+				case follow == nil:
+					log.Println("follow needs a detection model given with -follow-model")
+				case phase.is(Flying):
+					stopOrbit(tracker)
+					stopLock(tracker)
+					startFollow()
+				}
+			},
+			"menu": func() {
+				settings.toggle()
+			},
+			"lights": func() {
+				pilot.Lights(lights.next())
+			},
+			"reload": func() {
+				reloader.reload()
+			},
+			"record": func() {
+				session.toggle()
+			},
+			"camera": func() {
+				if cameras != nil {
+					cameras.switchCamera()
+				}
+			},
+			"layer-classification": func() { toggleLayer("classification") },
+			"layer-regions":        func() { toggleLayer("regions") },
+			"layer-status":         func() { toggleLayer("status") },
+			"layer-battery":        func() { toggleLayer("battery") },
+			"layer-commands":       func() { toggleLayer("commands") },
+			"layer-history":        func() { toggleLayer("history") },
+			"trim": func() {
+				// only on the ground, where it can be level
+				if !phase.is(Disarmed) && !phase.is(Armed) {
+					return
+				}
+				trim.request()
+				pilot.FlatTrim()
+				acks.expect("flat trim", time.Now(), minidrone.FlatTrimChange)
+			},
+		}
+
+		// take off and land when the trigger labels are seen
+		if triggers != nil {
+			go func() {
+				for action := range triggerRequests {
+					action := action
+					halt.control(func() {
+						events.record("trigger", action)
+						actions[action]()
+					})
+				}
+			}()
+		}
+
+		// anything done from the controller or keyboard stops the demo
+		// script first, so that the pilot takes over from it for good,
+		// except while the banner is up, when anything but emergency only
+		// skips the banner
+		manual := make(map[string]func())
+		for action, do := range actions {
+			action, do := action, do
+			manual[action] = func() {
+				halt.control(func() {
+					if action != "emergency" && intro.showing(time.Now()) {
+						intro.skip()
+						return
+					}
+					demo.abort("controller input")
+					do()
+				})
+			}
+		}
+
+		onButtons(stick, buttons, manual, settings.press)
+
+		// classify less often while the CPU is hot, to let it cool
+		if *hotTemp > 0 {
+			halt.every(5*time.Second, func() {
+				temp, err := readTemp(*tempSource)
+				if err != nil {
+					log.Println("temperature:", err)
+					return
+				}
+				atomic.StoreInt32(&cpuTemp, int32(temp*100))
+				every := thermalThrottle(int(atomic.LoadInt32(&classifyEvery)), *classifyEveryN, temp, *hotTemp, *coolTemp)
+				atomic.StoreInt32(&classifyEvery, int32(every))
+			})
+		}
+
+		// show frames at a steady rate, whatever the processing is doing,
+		// and fly from the keyboard when a key is pressed in the window
+		kb := newKeyboard(keys, manual)
+		halt.every(time.Second/time.Duration(*displayFPS), func() {
+			if sim != nil {
+				simWindow.ShowImage(sim.draw())
+			}
+			if frame, ok := display.take(); ok {
+				window.ShowImage(frame)
+			}
+			// keys are read whether or not there is a new frame, so that
+			// they still work when the camera stalls
+			if key := window.WaitKey(1); key >= 0 {
+				kb.press(key)
+			}
+		})
+
+		stick.On(joystick.L2, func(data interface{}) {
+			val := float64(data.(int16))
+			axes.record(joystick.L2, val)
+			l2.Store(val)
 		})
 
-		stick.On(joystick.XPress, func(data interface{}) {
-			drone.Land()
+		stick.On(joystick.R2, func(data interface{}) {
+			val := float64(data.(int16))
+			axes.record(joystick.R2, val)
+			r2.Store(val)
 		})
 
 		stick.On(joystick.LeftX, func(data interface{}) {
 			val := float64(data.(int16))
+			axes.record(joystick.LeftX, val)
 			leftX.Store(val)
 		})
 
 		stick.On(joystick.LeftY, func(data interface{}) {
 			val := float64(data.(int16))
+			axes.record(joystick.LeftY, val)
 			leftY.Store(val)
 		})
 
-		stick.On(joystick.RightX, func(data interface{}) {
+		pitchRoll.On(joystick.RightX, func(data interface{}) {
 			val := float64(data.(int16))
+			axes.record(joystick.RightX, val)
 			rightX.Store(val)
 		})
 
-		stick.On(joystick.RightY, func(data interface{}) {
+		pitchRoll.On(joystick.RightY, func(data interface{}) {
 			val := float64(data.(int16))
+			axes.record(joystick.RightY, val)
 			rightY.Store(val)
 		})
 
-		gobot.Every(10*time.Millisecond, func() {
+		halt.every(10*time.Millisecond, func() {
+			if !phase.is(Flying) || paused.Load().(bool) || demo.isRunning() {
+				return
+			}
 			rightStick := getRightStick()
+			guard := propGuard(alt.height(), *guardAltitude, *guardMin)
+			guardFactor.Store(guard)
+
+			// moving the stick takes over from the orbit or centering over
+			// the landing pad straight away
+			if controls.rightMoved(rightStick) {
+				stopOrbit(tracker)
+				stopCentering()
+				stopFollow()
+			} else if pitch, roll, done, ok := padCommands(time.Now()); ok {
+				if done {
+					land()
+					return
+				}
+				if pitch < 0 {
+					pilot.Backward(-pitch)
+				} else {
+					pilot.Forward(pitch)
+				}
+				if roll < 0 {
+					pilot.Left(-roll)
+				} else {
+					pilot.Right(roll)
+				}
+				return
+			} else if roll, _, ok := orbitCommands(tracker); ok {
+				pilot.Forward(0)
+				if roll < 0 {
+					pilot.Left(-roll)
+				} else {
+					pilot.Right(roll)
+				}
+				return
+			}
+
+			// pitch is positive for forward and roll for right
+			var pitch, roll int
+			switch {
+			case rightStick.y < -controls.Deadzones.RightY:
+				pitch = guarded(int(float64(command(rightStick.y))*forwardDamping()), guard)
+			case rightStick.y > controls.Deadzones.RightY:
+				pitch = -guarded(command(rightStick.y), guard)
+			}
+			switch {
+			case rightStick.x > controls.Deadzones.RightX:
+				roll = guarded(command(rightStick.x), guard)
+			case rightStick.x < -controls.Deadzones.RightX:
+				roll = -guarded(command(rightStick.x), guard)
+			}
 
 			switch {
-			case rightStick.y < -10:
-				drone.Forward(minidrone.ValidatePitch(rightStick.y, offset))
-			case rightStick.y > 10:
-				drone.Backward(minidrone.ValidatePitch(rightStick.y, offset))
+			case pitch > 0:
+				pilot.Forward(pitch)
+			case pitch < 0:
+				pilot.Backward(-pitch)
 			default:
-				drone.Forward(0)
+				pilot.Forward(0)
 			}
 
 			switch {
-			case rightStick.x > 10:
-				drone.Right(minidrone.ValidatePitch(rightStick.x, offset))
-			case rightStick.x < -10:
-				drone.Left(minidrone.ValidatePitch(rightStick.x, offset))
+			case roll > 0:
+				pilot.Right(roll)
+			case roll < 0:
+				pilot.Left(-roll)
 			default:
-				drone.Right(0)
+				pilot.Right(0)
 			}
 		})
 
-		gobot.Every(10*time.Millisecond, func() {
+		settle := &throttleSettle{power: *settlePower, duration: *settleTime}
+		halt.every(10*time.Millisecond, func() {
+			if !phase.is(Flying) || paused.Load().(bool) || demo.isRunning() {
+				return
+			}
 			leftStick := getLeftStick()
+
+			// moving the stick takes over from following a locked object
+			// or target
+			if controls.leftMoved(leftStick) {
+				stopLock(tracker)
+				stopFollow()
+			}
+			lockYaw, lockUp, following := lockCommands(tracker)
+			if yaw, up, ok := followCommands(); ok {
+				lockYaw, lockUp, following = yaw, up, true
+			}
+
+			// climb is positive for up and negative for down
+			var climb int
 			switch {
-			case leftStick.y < -10:
-				drone.Up(minidrone.ValidatePitch(leftStick.y, offset))
-			case leftStick.y > 10:
-				drone.Down(minidrone.ValidatePitch(leftStick.y, offset))
-			default:
-				drone.Up(0)
+			case leftStick.y < -controls.Deadzones.LeftY:
+				settle.moved()
+				climb = command(leftStick.y)
+			case leftStick.y > controls.Deadzones.LeftY:
+				settle.moved()
+				climb = -command(leftStick.y)
+			case following:
+				climb = lockUp
+			case *hoverSettle:
+				climb = settle.idle(time.Now())
+			}
+
+			climb, limit := altitudeEnvelope(climb, alt.height(), *minAltitude, *maxAltitude)
+			altitudeLimit.Store(limit)
+			if climb < 0 {
+				pilot.Down(-climb)
+			} else {
+				pilot.Up(climb)
 			}
 
 			switch {
-			case leftStick.x > 20:
-				drone.Clockwise(minidrone.ValidatePitch(leftStick.x, offset))
-			case leftStick.x < -20:
-				drone.CounterClockwise(minidrone.ValidatePitch(leftStick.x, offset))
+			case leftStick.x > controls.Deadzones.LeftX:
+				stopOrbit(tracker)
+				pilot.Clockwise(command(leftStick.x))
+			case leftStick.x < -controls.Deadzones.LeftX:
+				stopOrbit(tracker)
+				pilot.CounterClockwise(command(leftStick.x))
 			default:
-				drone.Clockwise(0)
+				_, yaw, _ := orbitCommands(tracker)
+				if following {
+					yaw = lockYaw
+				}
+				if yaw < 0 {
+					pilot.CounterClockwise(-yaw)
+				} else {
+					pilot.Clockwise(yaw)
+				}
 			}
 		})
+
+		if demo != nil {
+			halt.every(10*time.Millisecond, func() {
+				if !demo.isRunning() {
+					return
+				}
+				left, right := getLeftStick(), getRightStick()
+				if controls.leftMoved(left) || controls.rightMoved(right) {
+					demo.abort("controller input")
+					if phase.is(Flying) {
+						pilot.Stop()
+					}
+					return
+				}
+				if paused.Load().(bool) {
+					// pausing has already left the drone hovering
+					demo.abort("paused")
+					return
+				}
+				if _, at := fence.status(); at {
+					demo.abort("at the fence")
+					if phase.is(Flying) {
+						pilot.Stop()
+					}
+					return
+				}
+				if pilot.isDegraded() {
+					demo.abort("connection degraded")
+					actions["land"]()
+					return
+				}
+				if _, low := batteryStatus(); low {
+					demo.abort("battery too low")
+					actions["land"]()
+				}
+			})
+
+			halt.onStop(func() { demo.abort("shutting down") })
+			demo.start(func(step scriptStep) {
+				halt.control(func() {
+					if scriptMoves[step.cmd] && !phase.is(Flying) {
+						return
+					}
+					switch step.cmd {
+					case "trim":
+						actions["trim"]()
+					case "takeoff":
+						if phase.is(Disarmed) {
+							phase.to(Armed)
+						}
+						actions["takeoff"]()
+					case "land":
+						actions["land"]()
+					case "hover":
+						if phase.is(Flying) {
+							pilot.Stop()
+						}
+					case "forward":
+						pilot.Forward(step.value)
+					case "backward":
+						pilot.Backward(step.value)
+					case "left":
+						pilot.Left(step.value)
+					case "right":
+						pilot.Right(step.value)
+					case "up":
+						pilot.Up(step.value)
+					case "down":
+						pilot.Down(step.value)
+					case "clockwise":
+						pilot.Clockwise(step.value)
+					case "counterclockwise":
+						pilot.CounterClockwise(step.value)
+					}
+				})
+			})
+		}
 	}
 
 	robot := gobot.NewRobot("tensordrone",
-		[]gobot.Connection{joystickAdaptor, droneAdaptor},
-		[]gobot.Device{stick, drone, window, camera},
+		connections,
+		append(devices, drone, window, camera),
 		work,
 	)
 
-	robot.Start()
+	// halt the devices here rather than in the robot, leaving out the drone,
+	// which halts by landing, so that it is only told to land once
+	halted := append([]gobot.Device{window, camera}, devices...)
+	halt.onClose(func() error {
+		for _, d := range halted {
+			if err := d.Halt(); err != nil {
+				log.Printf("halting %v: %v", d.Name(), err)
+			}
+		}
+		for _, c := range connections {
+			if err := c.Finalize(); err != nil {
+				log.Printf("finalizing %v: %v", c.Name(), err)
+			}
+		}
+		return nil
+	})
+
+	// run until Ctrl-C, SIGTERM or a panic handling a frame, landing the
+	// drone before anything else
+	halt.notify()
+	if err := robot.Start(false); err != nil {
+		// the robot has logged what failed to start
+		os.Exit(1)
+	}
+	halt.wait()
+	stats.report(os.Stdout)
+	if err := track.write(*trackPath); err != nil {
+		fmt.Println(err)
+	}
 }
 
 func getLeftStick() pair {
 	s := pair{x: 0, y: 0}
-	s.x = leftX.Load().(float64)
-	s.y = leftY.Load().(float64)
+	slew, now := shaping().slew, time.Now()
+	s.x = slewLeftX.follow(leftX.Load().(float64), slew, now)
+	s.y = slewLeftY.follow(leftY.Load().(float64), slew, now)
 	return s
 }
 
 func getRightStick() pair {
 	s := pair{x: 0, y: 0}
-	s.x = rightX.Load().(float64)
-	s.y = rightY.Load().(float64)
+	slew, now := shaping().slew, time.Now()
+	s.x = slewRightX.follow(rightX.Load().(float64), slew, now)
+	s.y = slewRightY.follow(rightY.Load().(float64), slew, now)
 	return s
 }
 
+// registerProcessors registers tracker and then the FrameProcessors turned
+// on by flags, returning a function that releases them. Faces are blurred
+// in anything they record.
+func registerProcessors(tracker *objectTracker, faces *faceBlur) func() {
+	var closers []io.Closer
+	RegisterFrameProcessor(tracker)
+
+	if *avoidObstacles {
+		obstacles := newObstacleDetector()
+		closers = append(closers, obstacles)
+		RegisterFrameProcessor(obstacles)
+	}
+
+	if *landingPad {
+		pad := newPadDetector()
+		closers = append(closers, pad)
+		RegisterFrameProcessor(pad)
+	}
+
+	if *opticalFlow {
+		flow := newFlowProcessor()
+		closers = append(closers, flow)
+		RegisterFrameProcessor(flow)
+	}
+
+	if *qrCodes {
+		qr := newQRProcessor()
+		closers = append(closers, qr)
+		RegisterFrameProcessor(qr)
+	}
+
+	if *motion {
+		detector := newMotionDetector(*motionArea, *motionDir)
+		detector.faces = faces
+		closers = append(closers, detector)
+
+		var objectTrail *trail
+		if *trailLength > 0 {
+			objectTrail = newTrail(*trailLength)
+		}
+		RegisterFrameProcessor(&motionProcessor{detector: detector, trail: objectTrail})
+	}
+
+	if *grid {
+		RegisterFrameProcessor(gridProcessor)
+	}
+
+	return func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}
+}
+
+// drawClassification draws what the classifier made of the frame: the most
+// probable description and, with -bars, a bar for each of the ranked ones.
+func drawClassification(overlay *overlayLayout, labels translations, result ClassificationResult, ranked []prediction) {
+	switch {
+	case result.OK:
+		overlay.text(topLeft, fmt.Sprintf("description: %v, maxVal: %v", labels.translate(result.Label), result.Score))
+	case *onDemand:
+		overlay.text(topLeft, "press classify to identify")
+	}
+	if result.OK {
+		// bottom lines stack upwards, so go from worst to best
+		for i := len(ranked) - 1; i >= 0; i-- {
+			overlay.bar(bottomRight, labels.translate(ranked[i].label), float64(ranked[i].score))
+		}
+	}
+}
+
+// builtinJoystick reports whether name is one of the joystick configurations
+// built into gobot, rather than a mapping file.
+func builtinJoystick(name string) bool {
+	switch name {
+	case joystick.Dualshock3, joystick.Dualshock4, joystick.TFlightHotasX,
+		joystick.Xbox360, joystick.Xbox360RockBandDrums, joystick.Shield:
+		return true
+	}
+	return false
+}
+
+// checkFile returns an error naming the file if there is nothing at path.
+func checkFile(what, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%v file %v not found", what, path)
+		}
+		return fmt.Errorf("%v file %v: %v", what, path, err)
+	}
+	return nil
+}
+
+// classifyImage runs the classifier on a single image file and writes the
+// n most probable descriptions to w.
+func classifyImage(args []string, n int, w io.Writer) error {
+	if len(args) < 3 {
+		return errors.New("How to run:\n\ttensordrone [flags] classify [imagefile] [modelfile] [descriptionsfile]")
+	}
+	if err := checkFile("model", args[1]); err != nil {
+		return err
+	}
+
+	descriptions, err := readDescriptions(args[2])
+	if err != nil {
+		return err
+	}
+
+	img := gocv.IMRead(args[0], gocv.IMReadColor)
+	if img.Empty() {
+		return fmt.Errorf("cannot read image %v", args[0])
+	}
+	defer img.Close()
+
+	cls, err := newClassifier(args[1], descriptions, *backend, *target)
+	if err != nil {
+		return err
+	}
+	defer cls.Close()
+	if err := cls.check(); err != nil {
+		return err
+	}
+
+	preds, err := cls.predict(img, n)
+	if err != nil {
+		return err
+	}
+	for _, p := range preds {
+		fmt.Fprintf(w, "%v: %v\n", p.label, p.score)
+	}
+	return nil
+}
+
 // readDescriptions reads the descriptions from a file
 // and returns a slice of its lines.
 func readDescriptions(path string) ([]string, error) {
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mappingDir is where named joystick mappings are kept, under the user's
+// config directory, such as $XDG_CONFIG_HOME on Linux.
+var mappingDir = filepath.Join("fosdem-drone", "joysticks")
+
+// resolveMapping turns a joystick mapping argument into the file to load.
+// A path to an existing file is used as it is. Otherwise a bare name such
+// as dualshock3 is looked up as dualshock3.json in the mapping directory,
+// and if it is not there it is passed on as it is, so that the names of
+// the mappings built into gobot still work.
+func resolveMapping(name string) string {
+	if _, err := os.Stat(name); err == nil || strings.ContainsRune(name, os.PathSeparator) {
+		return name
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return name
+	}
+	file := filepath.Join(dir, mappingDir, strings.TrimSuffix(name, ".json")+".json")
+	if _, err := os.Stat(file); err == nil {
+		return file
+	}
+	return name
+}
+
+// flightActions are the actions that must have a button on the joystick to
+// fly safely. Any other action without one only gets a warning.
+var flightActions = []string{"arm", "takeoff", "land", "stop", "emergency"}
+
+// checkMapping checks that the joystick mapping file at path has the axes
+// needed to fly and a button for each of the flight actions in buttons,
+// which maps actions to buttons, naming exactly what is missing. The
+// joystick driver cannot tell, and would otherwise just never send the
+// events for them. Any other bound button that is missing is logged.
+func checkMapping(path string, axes []string, buttons map[string]string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var mapping joystickMapping
+	if err := json.Unmarshal(b, &mapping); err != nil {
+		return fmt.Errorf("joystick file %v is not a valid mapping: %v", path, err)
+	}
+
+	has := make(map[string]bool)
+	for _, a := range mapping.Axis {
+		has["axis "+a.Name] = true
+	}
+	for _, b := range mapping.Buttons {
+		has["button "+b.Name] = true
+	}
+
+	var missing []string
+	for _, a := range axes {
+		if !has["axis "+a] {
+			missing = append(missing, "axis "+a)
+		}
+	}
+	critical := make(map[string]bool)
+	for _, action := range flightActions {
+		critical[action] = true
+	}
+	var optional []string
+	for _, action := range actionNames() {
+		button := buttons[action]
+		if button == "" || has["button "+button] {
+			continue
+		}
+		what := fmt.Sprintf("button %v (%v)", button, action)
+		if critical[action] {
+			missing = append(missing, what)
+		} else {
+			optional = append(optional, what)
+		}
+	}
+
+	if len(optional) > 0 {
+		sort.Strings(optional)
+		log.Printf("joystick file %v has no %v, so those actions cannot be used from the joystick", path, strings.Join(optional, ", "))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("joystick file %v is missing %v, which are needed to fly", path, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+// checkMats feeds synthetic frames through the classifier, the frame
+// processors, the overlay and the display buffer, the same path camera
+// frames take, and fails if they leave any more gocv Mats open than there
+// were before. Mats are only counted when built with -tags matprofile.
+func checkMats(args []string, w io.Writer) error {
+	if len(args) < 3 {
+		return errors.New("How to run:\n\ttensordrone [flags] matcheck [frames] [modelfile] [descriptionsfile]")
+	}
+	frames, err := strconv.Atoi(args[0])
+	if err != nil || frames < 1 {
+		return fmt.Errorf("frames must be a positive number, not %v", args[0])
+	}
+	if _, ok := matCount(); !ok {
+		return errors.New("Mats can only be counted when built with -tags matprofile")
+	}
+	if err := checkFile("model", args[1]); err != nil {
+		return err
+	}
+	descriptions, err := readDescriptions(args[2])
+	if err != nil {
+		return err
+	}
+
+	cls, err := newClassifier(args[1], descriptions, *backend, *target)
+	if err != nil {
+		return err
+	}
+	defer cls.Close()
+	if err := cls.check(); err != nil {
+		return err
+	}
+	tracker := &objectTracker{}
+	defer tracker.stop()
+	release := registerProcessors(tracker, nil)
+	defer release()
+	display := newFrameBuffer()
+	defer display.Close()
+	rotated := gocv.NewMat()
+	defer rotated.Close()
+	img := gocv.NewMatWithSize(480, 640, gocv.MatTypeCV8UC3)
+	defer img.Close()
+
+	// the first frame may set up Mats that are kept for the next ones, so
+	// count from after it
+	run := func() error {
+		gocv.RandU(&img, gocv.NewScalar(0, 0, 0, 0), gocv.NewScalar(255, 255, 255, 0))
+		frame := orient(img, &rotated)
+		preds, err := cls.predict(frame, 5)
+		if err != nil {
+			return err
+		}
+		result := ClassificationResult{Label: preds[0].label, Score: preds[0].score, OK: true}
+		processFrame(frame, result)
+		drawClassification(newOverlayLayout(&frame), nil, result, preds)
+		display.put(frame)
+		display.take()
+		return nil
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	before, _ := matCount()
+	for i := 1; i < frames; i++ {
+		if err := run(); err != nil {
+			return err
+		}
+	}
+	after, _ := matCount()
+
+	fmt.Fprintf(w, "%d open Mats after the first frame, %d after %d frames\n", before, after, frames)
+	if after != before {
+		return fmt.Errorf("%d Mats were not closed", after-before)
+	}
+	return nil
+}
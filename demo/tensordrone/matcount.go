@@ -0,0 +1,11 @@
+//go:build matprofile
+// +build matprofile
+
+package main
+
+import "gocv.io/x/gocv"
+
+// matCount returns how many gocv Mats are open.
+func matCount() (int, bool) {
+	return gocv.MatProfile.Count(), true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// menuItem is a setting that can be changed from the menu.
+type menuItem struct {
+	name   string
+	format string
+	step   float64
+	min    float64
+	max    float64
+	get    func() float64
+	set    func(float64)
+
+	// show, if set, is shown as the value instead of get
+	show func() string
+}
+
+// menu is an overlay listing settings to change in flight with the
+// controller. Up and down pick a setting, and left and right change it.
+// The last line is resume, which closes the menu.
+type menu struct {
+	sync.Mutex
+	open     bool
+	selected int
+	items    []menuItem
+}
+
+func newMenu(items []menuItem) *menu {
+	return &menu{items: items}
+}
+
+// toggle opens or closes the menu.
+func (m *menu) toggle() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.open = !m.open
+	m.selected = 0
+}
+
+// press handles a d-pad button, returning false if the menu is closed or
+// the button is not one it uses, in which case the button does its usual
+// action instead.
+func (m *menu) press(button string) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.open {
+		return false
+	}
+
+	// the line after the items is resume
+	lines := len(m.items) + 1
+	switch button {
+	case "up":
+		m.selected = (m.selected + lines - 1) % lines
+	case "down":
+		m.selected = (m.selected + 1) % lines
+	case "left", "right":
+		if m.selected == len(m.items) {
+			m.open = false
+			return true
+		}
+		item := m.items[m.selected]
+		step := item.step
+		if button == "left" {
+			step = -step
+		}
+		item.set(math.Max(item.min, math.Min(item.max, item.get()+step)))
+	default:
+		return false
+	}
+	return true
+}
+
+// draw shows the menu in the middle of img, if it is open.
+func (m *menu) draw(img *gocv.Mat) {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.open {
+		return
+	}
+
+	lines := make([]string, 0, len(m.items)+1)
+	for _, item := range m.items {
+		if item.show != nil {
+			lines = append(lines, fmt.Sprintf("%-12s %v", item.name, item.show()))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%-12s "+item.format, item.name, item.get()))
+	}
+	lines = append(lines, "resume")
+
+	// size the box to the widest line
+	var width, height int
+	for _, line := range lines {
+		size, baseline := gocv.GetTextSizeWithBaseline("> "+line, overlayFont, overlayScale, overlayThickness)
+		if size.X > width {
+			width = size.X
+		}
+		height = size.Y + baseline + overlaySpacing
+	}
+	box := image.Rect(0, 0, width+2*overlayMargin, height*len(lines)+2*overlayMargin)
+	box = box.Add(image.Pt((img.Cols()-box.Dx())/2, (img.Rows()-box.Dy())/2))
+	gocv.Rectangle(img, box, color.RGBA{0, 0, 0, 0}, -1)
+
+	for i, line := range lines {
+		c, prefix := overlayColor, "  "
+		if i == m.selected {
+			c, prefix = color.RGBA{255, 255, 0, 0}, "> "
+		}
+		pt := image.Pt(box.Min.X+overlayMargin, box.Min.Y+overlayMargin+(i+1)*height-overlaySpacing)
+		gocv.PutText(img, prefix+line, pt, overlayFont, overlayScale, c, overlayThickness)
+	}
+}
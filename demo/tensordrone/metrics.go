@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var metricsAddr = flag.String("metrics", "", "serve Prometheus metrics at http://ADDR/metrics, such as :9100, for watching an unattended demo")
+
+// inferenceBuckets are the upper bounds, in seconds, of the inference
+// latency histogram buckets.
+var inferenceBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// gaugeFunc is a gauge read when the metrics are scraped.
+type gaugeFunc struct {
+	name, help string
+	value      func() float64
+}
+
+// metrics counts what the demo does for Prometheus to scrape, in its text
+// format, which is simple enough to write without the client library. It
+// is nil when there is no -metrics, and then counts nothing.
+type metrics struct {
+	sync.Mutex
+	frames   uint64
+	commands map[string]uint64
+	labels   map[string]uint64
+
+	// the inference latency histogram, with a count for each bucket
+	buckets    []uint64
+	latencySum float64
+	latencies  uint64
+
+	gauges []gaugeFunc
+}
+
+// newMetrics starts serving metrics on addr, or returns nil if addr is empty.
+func newMetrics(addr string) (*metrics, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	m := &metrics{
+		commands: make(map[string]uint64),
+		labels:   make(map[string]uint64),
+		buckets:  make([]uint64, len(inferenceBuckets)),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			log.Println("metrics:", err)
+		}
+	}()
+	return m, nil
+}
+
+// gauge adds a gauge whose value is read from value at each scrape. Call it
+// before the demo starts.
+func (m *metrics) gauge(name, help string, value func() float64) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.gauges = append(m.gauges, gaugeFunc{name: name, help: help, value: value})
+}
+
+// frame counts a processed camera frame.
+func (m *metrics) frame() {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.frames++
+}
+
+// classified counts a classification as label and records how long it took.
+func (m *metrics) classified(label string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.labels[label]++
+	s := d.Seconds()
+	for i, bound := range inferenceBuckets {
+		if s <= bound {
+			m.buckets[i]++
+		}
+	}
+	m.latencySum += s
+	m.latencies++
+}
+
+// command counts a command sent to the drone.
+func (m *metrics) command(name string) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.commands[name]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	m.Lock()
+	defer m.Unlock()
+
+	writeHeader(b, "tensordrone_frames_total", "counter", "Camera frames processed.")
+	fmt.Fprintf(b, "tensordrone_frames_total %d\n", m.frames)
+
+	writeHeader(b, "tensordrone_inference_seconds", "histogram", "How long each classification took.")
+	for i, bound := range inferenceBuckets {
+		fmt.Fprintf(b, "tensordrone_inference_seconds_bucket{le=\"%g\"} %d\n", bound, m.buckets[i])
+	}
+	fmt.Fprintf(b, "tensordrone_inference_seconds_bucket{le=\"+Inf\"} %d\n", m.latencies)
+	fmt.Fprintf(b, "tensordrone_inference_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(b, "tensordrone_inference_seconds_count %d\n", m.latencies)
+
+	writeHeader(b, "tensordrone_classifications_total", "counter", "Classifications by label.")
+	writeLabelled(b, "tensordrone_classifications_total", "label", m.labels)
+
+	writeHeader(b, "tensordrone_commands_total", "counter", "Commands sent to the drone by name.")
+	writeLabelled(b, "tensordrone_commands_total", "command", m.commands)
+
+	for _, g := range m.gauges {
+		writeHeader(b, g.name, "gauge", g.help)
+		fmt.Fprintf(b, "%v %g\n", g.name, g.value())
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// writeLabelled writes a counter for each key of counts, in order, as the
+// label key.
+func writeLabelled(w io.Writer, name, key string, counts map[string]uint64) {
+	values := make([]string, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, v := range values {
+		fmt.Fprintf(w, "%v{%v=\"%v\"} %d\n", name, key, escape.Replace(v), counts[v])
+	}
+}
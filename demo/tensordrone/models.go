@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// The input and output layers are those of the Inception graph for
+// Tensorflow models and of GoogLeNet for Caffe ones. ONNX and Darknet
+// models have a single input and output, which are used when no layer is
+// named. Caffe and Darknet models come as two files, the weights, given
+// as the model file, and the network description, given with
+// -model-config. Models whose output layer gives raw scores
+// rather than probabilities, as many exported to ONNX do, need -softmax
+// for -bars, -batch and the score thresholds to make sense.
+var (
+	modelFormatFlag = flag.String("model-format", "auto", "format of the model file, tensorflow, onnx, caffe or darknet, or auto to go by its extension")
+	modelConfig     = flag.String("model-config", "", "network description that goes with the model file, the .prototxt for Caffe or the .cfg for Darknet")
+	inputLayer      = flag.String("input-layer", "", "name of the network's input layer, instead of the model format's usual one")
+	outputLayer     = flag.String("output-layer", "", "name of the network's output layer, instead of the model format's usual one")
+	softmax         = flag.Bool("softmax", false, "turn the scores from the output layer into probabilities, for models whose output is not already a softmax")
+)
+
+// modelFormat is a kind of model file gocv can read, and the layer names it
+// usually has.
+type modelFormat struct {
+	name          string
+	extensions    []string
+	input, output string
+	// scale is what pixel values are multiplied by for the network, after
+	// mean is taken away from them
+	scale float64
+	mean  gocv.Scalar
+	// swapRB is whether the network takes RGB rather than the camera's BGR
+	swapRB bool
+	// config is whether the format needs -model-config
+	config bool
+	read   func(model, config string) gocv.Net
+}
+
+var modelFormats = []modelFormat{
+	{
+		name:       "tensorflow",
+		extensions: []string{".pb"},
+		input:      "input",
+		output:     "softmax2",
+		scale:      1,
+		swapRB:     true,
+		read:       func(model, _ string) gocv.Net { return gocv.ReadNetFromTensorflow(model) },
+	},
+	{
+		name:       "onnx",
+		extensions: []string{".onnx"},
+		scale:      1,
+		swapRB:     true,
+		read:       func(model, _ string) gocv.Net { return gocv.ReadNetFromONNX(model) },
+	},
+	{
+		name:       "caffe",
+		extensions: []string{".caffemodel"},
+		input:      "data",
+		output:     "prob",
+		scale:      1,
+		// the mean BGR pixel of ImageNet, which Caffe's models are
+		// trained with taken away
+		mean:   gocv.NewScalar(104, 117, 123, 0),
+		swapRB: false,
+		config: true,
+		read:   func(model, config string) gocv.Net { return gocv.ReadNetFromCaffe(config, model) },
+	},
+	{
+		// gocv has no Darknet reader of its own, but ReadNet knows the
+		// files by their extensions
+		name:       "darknet",
+		extensions: []string{".weights"},
+		scale:      1.0 / 255,
+		swapRB:     true,
+		config:     true,
+		read:       gocv.ReadNet,
+	},
+}
+
+// findModelFormat returns the format named by -model-format, or with auto,
+// the one whose extension model has, falling back to Tensorflow.
+func findModelFormat(name, model string) (modelFormat, error) {
+	name = strings.ToLower(name)
+	if name == "auto" {
+		ext := strings.ToLower(filepath.Ext(model))
+		for _, f := range modelFormats {
+			for _, e := range f.extensions {
+				if e == ext {
+					return f, nil
+				}
+			}
+		}
+		return modelFormats[0], nil
+	}
+
+	names := make([]string, 0, len(modelFormats))
+	for _, f := range modelFormats {
+		if f.name == name {
+			return f, nil
+		}
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+	return modelFormat{}, fmt.Errorf("-model-format %q is not one of auto, %v", name, strings.Join(names, ", "))
+}
+
+// layers returns the input and output layer names to use with f, from
+// -input-layer and -output-layer if they are given.
+func (f modelFormat) layers() (input, output string) {
+	input, output = f.input, f.output
+	if *inputLayer != "" {
+		input = *inputLayer
+	}
+	if *outputLayer != "" {
+		output = *outputLayer
+	}
+	return input, output
+}
+
+// softmaxScores turns raw scores into probabilities that add up to 1, in
+// place.
+func softmaxScores(scores []float32) {
+	max := scores[0]
+	for _, s := range scores {
+		if s > max {
+			max = s
+		}
+	}
+	var sum float64
+	for i, s := range scores {
+		e := math.Exp(float64(s - max))
+		scores[i] = float32(e)
+		sum += e
+	}
+	for i := range scores {
+		scores[i] = float32(float64(scores[i]) / sum)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	motion     = flag.Bool("motion", false, "security drone: record video while motion is detected")
+	motionArea = flag.Float64("motion-area", 2000, "total area in pixels of moving contours that counts as motion")
+	motionDir  = flag.String("motion-dir", "motion", "directory motion recordings are written to")
+)
+
+// motionHold is how many frames without motion end a recording, so brief
+// pauses in movement do not split it into many files.
+const motionHold = 30
+
+// motionDetector finds moving regions using background subtraction and
+// records video while they add up to more than a minimum area.
+type motionDetector struct {
+	mog2    gocv.BackgroundSubtractorMOG2
+	mask    gocv.Mat
+	kernel  gocv.Mat
+	minArea float64
+	dir     string
+
+	writer *gocv.VideoWriter
+	quiet  int
+
+	// faces, if set, are blurred in the recordings
+	faces *faceBlur
+}
+
+func newMotionDetector(minArea float64, dir string) *motionDetector {
+	return &motionDetector{
+		mog2:    gocv.NewBackgroundSubtractorMOG2(),
+		mask:    gocv.NewMat(),
+		kernel:  gocv.GetStructuringElement(gocv.MorphRect, image.Pt(3, 3)),
+		minArea: minArea,
+		dir:     dir,
+	}
+}
+
+// Close stops any recording and releases the detector.
+func (m *motionDetector) Close() error {
+	m.stop()
+	m.kernel.Close()
+	m.mask.Close()
+	return m.mog2.Close()
+}
+
+// detect returns the bounding boxes of the moving regions in img, and
+// whether together they are large enough to count as motion.
+func (m *motionDetector) detect(img gocv.Mat) ([]image.Rectangle, bool) {
+	m.mog2.Apply(img, &m.mask)
+	gocv.Threshold(m.mask, &m.mask, 25, 255, gocv.ThresholdBinary)
+	gocv.Dilate(m.mask, &m.mask, m.kernel)
+
+	contours := gocv.FindContours(m.mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	var rects []image.Rectangle
+	var areas []float64
+	for i := 0; i < contours.Size(); i++ {
+		area := gocv.ContourArea(contours.At(i))
+		if area < minContourArea {
+			continue
+		}
+		areas = append(areas, area)
+		rects = append(rects, gocv.BoundingRect(contours.At(i)))
+	}
+	return rects, isMotion(areas, m.minArea)
+}
+
+// minContourArea drops specks of sensor noise before they are counted.
+const minContourArea = 50
+
+// isMotion reports whether contours with the given areas add up to at
+// least minArea.
+func isMotion(areas []float64, minArea float64) bool {
+	var total float64
+	for _, a := range areas {
+		total += a
+	}
+	return total > 0 && total >= minArea
+}
+
+// update starts or stops recording depending on whether there is motion,
+// and writes img to the current recording.
+func (m *motionDetector) update(img gocv.Mat, moving bool) {
+	if moving {
+		m.quiet = 0
+		if m.writer == nil {
+			m.start(img)
+		}
+	} else if m.writer != nil {
+		m.quiet++
+		if m.quiet > motionHold {
+			m.stop()
+		}
+	}
+
+	if m.writer != nil {
+		out, closeIt := m.faces.blurred(img)
+		m.writer.Write(out)
+		if closeIt {
+			out.Close()
+		}
+	}
+}
+
+func (m *motionDetector) start(img gocv.Mat) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		log.Println("motion:", err)
+		return
+	}
+
+	name := filepath.Join(m.dir, fmt.Sprintf("motion-%v.avi", time.Now().Format("20060102-150405")))
+	writer, err := gocv.VideoWriterFile(name, "MJPG", 15, img.Cols(), img.Rows(), true)
+	if err != nil {
+		log.Println("motion:", err)
+		return
+	}
+	log.Println("motion: recording to", name)
+	m.writer = writer
+}
+
+func (m *motionDetector) stop() {
+	if m.writer == nil {
+		return
+	}
+	m.writer.Close()
+	m.writer = nil
+	log.Println("motion: recording stopped")
+}
+
+// drawMotion outlines each moving region on img.
+func drawMotion(img *gocv.Mat, rects []image.Rectangle) {
+	for _, r := range rects {
+		gocv.Rectangle(img, r, color.RGBA{255, 0, 0, 0}, 2)
+	}
+}
+
+// motionProcessor is a FrameProcessor that outlines moving regions,
+// records while there is motion and, if it has one, extends the trail of
+// the largest moving object.
+type motionProcessor struct {
+	detector *motionDetector
+	trail    *trail
+}
+
+// Process implements FrameProcessor.
+func (m *motionProcessor) Process(img gocv.Mat, result ClassificationResult) {
+	rects, moving := m.detector.detect(img)
+
+	if m.trail != nil {
+		if p, ok := centroid(rects); ok {
+			m.trail.add(p)
+		}
+		m.trail.draw(&img)
+	}
+
+	drawMotion(&img, rects)
+	m.detector.update(img, moving)
+}
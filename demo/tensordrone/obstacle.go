@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var avoidObstacles = flag.Bool("avoid", false, "experimental: slow and then stop forward flight when the bottom middle of the video quickly grows darker, as it does when closing in on something")
+
+const (
+	// obstacleWindow is how far back frames are compared to notice the
+	// view ahead darkening.
+	obstacleWindow = 500 * time.Millisecond
+	// obstacleRise is how much of the region ahead turning dark within
+	// obstacleWindow counts as about to hit something.
+	obstacleRise = 0.25
+	// obstacleHold is how long forward flight stays stopped after that.
+	obstacleHold = time.Second
+	// obstacleDark is how much darker than the whole frame a pixel ahead
+	// must be to count as dark.
+	obstacleDark = 0.6
+)
+
+// obstacleFactor is how much forward commands are scaled by to avoid an
+// obstacle, from 0 to 1. It is unset until the detector has run.
+var obstacleFactor atomic.Value
+
+// forwardDamping returns the scaling for forward commands.
+func forwardDamping() float64 {
+	if f, ok := obstacleFactor.Load().(float64); ok {
+		return f
+	}
+	return 1
+}
+
+// obstacleSample is how dark the region ahead was at a time.
+type obstacleSample struct {
+	at   time.Time
+	dark float64
+	mean float64
+}
+
+// obstacleDetector is a FrameProcessor that guesses when the drone is about
+// to fly into something, without a depth sensor. A surface being closed
+// in on fills more and more of the view ahead and, blocking the light,
+// grows darker, so it watches the bottom middle of the frame for a dark
+// area quickly spreading across it. It is only a heuristic: it misses
+// bright obstacles and is fooled by flying into shadow.
+type obstacleDetector struct {
+	gray    gocv.Mat
+	mask    gocv.Mat
+	samples []obstacleSample
+	until   time.Time
+}
+
+func newObstacleDetector() *obstacleDetector {
+	return &obstacleDetector{gray: gocv.NewMat(), mask: gocv.NewMat()}
+}
+
+// Close releases the working images.
+func (o *obstacleDetector) Close() error {
+	o.mask.Close()
+	return o.gray.Close()
+}
+
+// aheadRegion is the bottom middle of a frame of the given size, where an
+// obstacle in the flight path shows first.
+func aheadRegion(w, h int) image.Rectangle {
+	return image.Rect(w/3, h/3, 2*w/3, 5*h/6)
+}
+
+// Process implements FrameProcessor.
+func (o *obstacleDetector) Process(img gocv.Mat, result ClassificationResult) {
+	now := time.Now()
+	gocv.CvtColor(img, &o.gray, gocv.ColorBGRToGray)
+	frameMean := o.gray.Mean().Val1
+
+	r := aheadRegion(img.Cols(), img.Rows())
+	ahead := o.gray.Region(r)
+	mean := ahead.Mean().Val1
+	gocv.Threshold(ahead, &o.mask, float32(frameMean*obstacleDark), 255, gocv.ThresholdBinaryInv)
+	ahead.Close()
+	dark := float64(gocv.CountNonZero(o.mask)) / float64(r.Dx()*r.Dy())
+
+	o.samples = append(o.samples, obstacleSample{at: now, dark: dark, mean: mean})
+	for len(o.samples) > 1 && now.Sub(o.samples[0].at) > obstacleWindow {
+		o.samples = o.samples[1:]
+	}
+
+	factor := obstacleDamping(o.samples[0], o.samples[len(o.samples)-1], obstacleRise)
+	if factor == 0 {
+		o.until = now.Add(obstacleHold)
+	}
+	if now.Before(o.until) {
+		factor = 0
+	}
+	obstacleFactor.Store(factor)
+}
+
+// obstacleDamping returns the forward scaling for the region ahead having
+// gone from then to now: 1 while it is not getting darker, falling to 0
+// as the dark part of it grows by rise.
+func obstacleDamping(then, now obstacleSample, rise float64) float64 {
+	grown := now.dark - then.dark
+	if grown <= 0 || now.mean >= then.mean {
+		return 1
+	}
+	if grown >= rise {
+		return 0
+	}
+	return 1 - grown/rise
+}
+
+// drawObstacle outlines the region ahead on img while forward flight is
+// being damped.
+func drawObstacle(img *gocv.Mat) {
+	if forwardDamping() < 1 {
+		gocv.Rectangle(img, aheadRegion(img.Cols(), img.Rows()), color.RGBA{255, 0, 0, 0}, 2)
+	}
+}
+
+// obstacleStatus describes the obstacle damping for the overlay, or
+// returns "" when there is none.
+func obstacleStatus() string {
+	switch f := forwardDamping(); {
+	case f == 0:
+		return "obstacle ahead: forward stopped"
+	case f < 1:
+		return fmt.Sprintf("obstacle ahead: forward at %.0f%%", f*100)
+	}
+	return ""
+}
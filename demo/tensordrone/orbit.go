@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"sync/atomic"
+)
+
+var (
+	orbitSpeed = flag.Int("orbit-speed", 20, "sideways command percent used to circle an object with the orbit button")
+	orbitTurn  = flag.Int("orbit-turn", 50, "most yaw command percent used to keep the circled object in the middle of the frame")
+)
+
+// orbiting is 1 while the drone is circling the tracked object.
+var orbiting int32
+
+// orbitCommands returns the sideways and yaw commands that circle the
+// tracked object, positive for right and clockwise. The drone flies
+// sideways and turns towards the object as it drifts off center, which
+// keeps it pointing at the object as it goes round. It returns false if
+// the drone should not be orbiting.
+func orbitCommands(tracker *objectTracker) (roll, yaw int, ok bool) {
+	if atomic.LoadInt32(&orbiting) == 0 {
+		return 0, 0, false
+	}
+	off, tracking := tracker.offset()
+	if !tracking {
+		// give up once the object is lost, rather than waiting for it
+		if !tracker.selecting() {
+			atomic.StoreInt32(&orbiting, 0)
+		}
+		return 0, 0, false
+	}
+	return *orbitSpeed, int(off * float64(*orbitTurn)), true
+}
+
+// startOrbit selects the object in the middle of the frame and circles it.
+func startOrbit(tracker *objectTracker) {
+	tracker.selectCenter()
+	atomic.StoreInt32(&orbiting, 1)
+}
+
+// isOrbiting reports whether the drone is circling an object.
+func isOrbiting() bool {
+	return atomic.LoadInt32(&orbiting) == 1
+}
+
+// stopOrbit stops circling, leaving the drone under manual control.
+func stopOrbit(tracker *objectTracker) {
+	if atomic.CompareAndSwapInt32(&orbiting, 1, 0) {
+		tracker.stop()
+	}
+}
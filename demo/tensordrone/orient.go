@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	autoOrient = flag.Bool("auto-orient", false, "rotate portrait camera frames to landscape before classifying and drawing on them")
+	orientCCW  = flag.Bool("orient-ccw", false, "with -auto-orient, rotate portrait frames counterclockwise instead of clockwise")
+)
+
+// orient returns img turned to landscape when -auto-orient is set and img
+// is portrait, rotating it into dst, and img unchanged otherwise. There is
+// no telling from the frame which way is up, so the direction is a flag.
+func orient(img gocv.Mat, dst *gocv.Mat) gocv.Mat {
+	if !*autoOrient || img.Rows() <= img.Cols() {
+		return img
+	}
+
+	code := gocv.Rotate90Clockwise
+	if *orientCCW {
+		code = gocv.Rotate90CounterClockwise
+	}
+	gocv.Rotate(img, dst, code)
+	return *dst
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"log"
+	"math"
+	"net"
+	"sync"
+)
+
+var oscAddr = flag.String("osc", "", "send an OSC message to this host:port whenever the classification changes, for VJ and music software")
+
+// oscAddress is the OSC address classifications are sent to, with the
+// label and its score as arguments.
+const oscAddress = "/classification"
+
+// oscSender sends classification changes as OSC messages over UDP. If
+// nothing is listening the messages are dropped, after logging once.
+type oscSender struct {
+	conn net.Conn
+
+	sync.Mutex
+	last    string
+	failing bool
+}
+
+// newOSCSender returns a sender to addr, or nil if addr is empty.
+func newOSCSender(addr string) (*oscSender, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &oscSender{conn: conn}, nil
+}
+
+// send sends label and score, if label is not the one last sent.
+func (o *oscSender) send(label string, score float32) {
+	if o == nil {
+		return
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	if label == o.last {
+		return
+	}
+	o.last = label
+
+	_, err := o.conn.Write(oscMessage(oscAddress, label, score))
+	switch {
+	case err != nil && !o.failing:
+		log.Printf("could not send OSC message, dropping them until it works again: %v", err)
+		o.failing = true
+	case err == nil:
+		o.failing = false
+	}
+}
+
+// Close closes the connection.
+func (o *oscSender) Close() error {
+	if o == nil {
+		return nil
+	}
+	return o.conn.Close()
+}
+
+// oscMessage encodes an OSC message with a string and a float argument.
+func oscMessage(address, s string, f float32) []byte {
+	var b bytes.Buffer
+	oscString(&b, address)
+	oscString(&b, ",sf")
+	oscString(&b, s)
+	binary.Write(&b, binary.BigEndian, math.Float32bits(f))
+	return b.Bytes()
+}
+
+// oscString writes s null terminated and padded to a multiple of 4 bytes.
+func oscString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.Write(make([]byte, 4-len(s)%4))
+}
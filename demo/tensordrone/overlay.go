@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// anchor is the corner of the frame that overlay text is stacked from.
+type anchor int
+
+const (
+	topLeft anchor = iota
+	topRight
+	bottomLeft
+	bottomRight
+)
+
+const (
+	overlayFont    = gocv.FontHersheyPlain
+	overlayScale   = 1.2
+	overlayMargin  = 10
+	overlaySpacing = 6
+)
+
+var (
+	textColor     = flag.String("text-color", "00ff00", "overlay text color as RGB hex, or auto for black or white depending on the video behind it")
+	textThickness = flag.Int("text-thickness", 2, "thickness of the overlay text lines")
+)
+
+// overlayColor and overlayThickness are the overlay text style, set from
+// -text-color and -text-thickness by setupOverlayStyle. With autoContrast,
+// overlayColor is only used where there is no video to contrast with.
+var (
+	overlayColor     = color.RGBA{0, 255, 0, 0}
+	overlayThickness = 2
+	autoContrast     bool
+)
+
+// setupOverlayStyle parses the overlay text color and thickness.
+func setupOverlayStyle(spec string, thickness int) error {
+	if thickness < 1 {
+		return fmt.Errorf("-text-thickness must be at least 1")
+	}
+	overlayThickness = thickness
+
+	spec = strings.TrimPrefix(strings.ToLower(spec), "#")
+	if spec == "auto" {
+		autoContrast = true
+		return nil
+	}
+	rgb, err := strconv.ParseUint(spec, 16, 32)
+	if err != nil || len(spec) != 6 {
+		return fmt.Errorf("-text-color %q is not an RGB hex color such as 00ff00, or auto", spec)
+	}
+	overlayColor = color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0}
+	return nil
+}
+
+// contrastColor returns the color for text over the area r of img: black
+// or white, whichever stands out more, in auto contrast mode, and the
+// overlay color otherwise.
+func contrastColor(img *gocv.Mat, r image.Rectangle) color.RGBA {
+	r = r.Intersect(image.Rect(0, 0, img.Cols(), img.Rows()))
+	if !autoContrast || r.Empty() {
+		return overlayColor
+	}
+
+	region := img.Region(r)
+	defer region.Close()
+
+	// the frames are BGR
+	mean := region.Mean()
+	if 0.114*mean.Val1+0.587*mean.Val2+0.299*mean.Val3 > 127 {
+		return color.RGBA{0, 0, 0, 0}
+	}
+	return color.RGBA{255, 255, 255, 0}
+}
+
+// overlayLayout places lines of text on a frame, stacking each one below
+// (or, at the bottom, above) the last one in the same corner so that they
+// never overlap whatever the frame size.
+type overlayLayout struct {
+	img  *gocv.Mat
+	used [4]int
+}
+
+func newOverlayLayout(img *gocv.Mat) *overlayLayout {
+	return &overlayLayout{img: img}
+}
+
+// text draws s in the given corner, in the overlay color.
+func (l *overlayLayout) text(a anchor, s string) {
+	size, baseline := gocv.GetTextSizeWithBaseline(s, overlayFont, overlayScale, overlayThickness)
+	pt := l.next(a, size, baseline, size.X)
+	c := contrastColor(l.img, image.Rect(pt.X, pt.Y-size.Y, pt.X+size.X, pt.Y+baseline))
+	gocv.PutText(l.img, s, pt, overlayFont, overlayScale, c, overlayThickness)
+}
+
+// textColor draws s in the given corner and color.
+func (l *overlayLayout) textColor(a anchor, s string, c color.RGBA) {
+	size, baseline := gocv.GetTextSizeWithBaseline(s, overlayFont, overlayScale, overlayThickness)
+	gocv.PutText(l.img, s, l.next(a, size, baseline, size.X), overlayFont, overlayScale, c, overlayThickness)
+}
+
+// next returns where to put the next line in corner a, given the size of
+// its text and the total width of the line, and reserves the space for it.
+func (l *overlayLayout) next(a anchor, size image.Point, baseline, width int) image.Point {
+	x := overlayMargin
+	if a == topRight || a == bottomRight {
+		x = l.img.Cols() - overlayMargin - width
+	}
+
+	// PutText places text by its baseline
+	var y int
+	if a == topLeft || a == topRight {
+		y = overlayMargin + l.used[a] + size.Y
+	} else {
+		y = l.img.Rows() - overlayMargin - l.used[a] - baseline
+	}
+	l.used[a] += size.Y + baseline + overlaySpacing
+
+	return image.Pt(x, y)
+}
+
+// barWidth is the length of a bar for a probability of 1.
+const barWidth = 150
+
+var bars = flag.Int("bars", 0, "draw the N most probable classifications as a bar graph")
+
+// bar draws a label with a filled bar after it as long as fraction of
+// barWidth, for showing how probable each classification is.
+func (l *overlayLayout) bar(a anchor, label string, fraction float64) {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+
+	size, baseline := gocv.GetTextSizeWithBaseline(label, overlayFont, overlayScale, overlayThickness)
+	pt := l.next(a, size, baseline, size.X+overlaySpacing+barWidth)
+	c := contrastColor(l.img, image.Rect(pt.X, pt.Y-size.Y, pt.X+size.X+overlaySpacing+barWidth, pt.Y+baseline))
+	gocv.PutText(l.img, label, pt, overlayFont, overlayScale, c, overlayThickness)
+
+	left := pt.X + size.X + overlaySpacing
+	gocv.Rectangle(l.img, image.Rect(left, pt.Y-size.Y, left+barWidth, pt.Y), c, 1)
+	gocv.Rectangle(l.img, image.Rect(left, pt.Y-size.Y, left+int(barWidth*fraction), pt.Y), c, -1)
+}
+
+var grid = flag.Bool("grid", false, "draw a rule-of-thirds grid and level line to help align the camera")
+
+// gridProcessor is a FrameProcessor that draws the alignment grid.
+var gridProcessor = FrameProcessorFunc(func(img gocv.Mat, result ClassificationResult) {
+	drawGrid(&img)
+})
+
+// drawGrid draws a rule-of-thirds grid over img, plus a level line across
+// the middle of the frame, so the camera mount can be lined up.
+func drawGrid(img *gocv.Mat) {
+	w, h := img.Cols(), img.Rows()
+	gridColor := color.RGBA{255, 255, 255, 0}
+	levelColor := color.RGBA{0, 255, 255, 0}
+
+	for i := 1; i < 3; i++ {
+		x := w * i / 3
+		y := h * i / 3
+		gocv.Line(img, image.Pt(x, 0), image.Pt(x, h), gridColor, 1)
+		gocv.Line(img, image.Pt(0, y), image.Pt(w, y), gridColor, 1)
+	}
+
+	gocv.Line(img, image.Pt(0, h/2), image.Pt(w, h/2), levelColor, 2)
+	gocv.Line(img, image.Pt(w/2, h/2-10), image.Pt(w/2, h/2+10), levelColor, 2)
+}
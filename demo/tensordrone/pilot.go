@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var cmdTimeout = flag.Duration("cmd-timeout", 200*time.Millisecond, "give up on a drone command that has not been sent after this long")
+
+// maxPending is how many timed out commands may still be stuck writing to
+// the drone before further commands are dropped rather than piling up.
+const maxPending = 8
+
+var errDegraded = errors.New("drone connection degraded, command dropped")
+
+// droneCommands is the part of the minidrone driver used to fly the drone.
+type droneCommands interface {
+	TakeOff() error
+	Land() error
+	Stop() error
+	Emergency() error
+	FlatTrim() error
+	HullProtection(protect bool) error
+	LightControl(id uint8, mode uint8, intensity uint8) error
+	Forward(val int) error
+	Backward(val int) error
+	Right(val int) error
+	Left(val int) error
+	Up(val int) error
+	Down(val int) error
+	Clockwise(val int) error
+	CounterClockwise(val int) error
+}
+
+// pilot sends every command to the drone, so that there is one place to
+// record what the drone was told to do, and to keep it inside the fence
+// whoever is flying it.
+type pilot struct {
+	drone   droneCommands
+	log     *timeline
+	metrics *metrics
+	alt     *altimeter
+	fence   *geofence
+	timeout time.Duration
+
+	pending  int32
+	degraded int32
+
+	rate *rateLimiter
+
+	sync.Mutex
+	// axes is the value last passed for each axis, signed as commands
+	// returns them, so that a repeat is told apart from a change even when it
+	// flips from one command of the axis to the other, such as forward to
+	// backward
+	axes [4]int
+	// asked is the pitch and roll last asked for, before the fence limited
+	// them into axes
+	asked [2]int
+}
+
+// The axes that movement commands set, in the order commands returns them.
+const (
+	pitchAxis = iota
+	rollAxis
+	throttleAxis
+	yawAxis
+)
+
+func newPilot(drone droneCommands, log *timeline, m *metrics, alt *altimeter, fence *geofence, timeout time.Duration, limit int) *pilot {
+	return &pilot{
+		drone:   drone,
+		log:     log,
+		metrics: m,
+		alt:     alt,
+		fence:   fence,
+		timeout: timeout,
+		rate:    &rateLimiter{limit: limit},
+	}
+}
+
+// isDegraded reports whether recent commands have been timing out.
+func (p *pilot) isDegraded() bool {
+	return atomic.LoadInt32(&p.degraded) == 1
+}
+
+// send runs f, a command written to the drone over BLE, giving up once the
+// command timeout has passed so that a hung write cannot stall the control
+// loops. The write itself cannot be cancelled, so it carries on in the
+// background.
+func (p *pilot) send(name string, f func() error) error {
+	if atomic.LoadInt32(&p.pending) >= maxPending {
+		return errDegraded
+	}
+	atomic.AddInt32(&p.pending, 1)
+	p.metrics.command(name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer atomic.AddInt32(&p.pending, -1)
+		done <- f()
+	}()
+
+	select {
+	case err := <-done:
+		if atomic.CompareAndSwapInt32(&p.degraded, 1, 0) {
+			log.Println("drone connection recovered")
+		}
+		return err
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&p.degraded, 0, 1) {
+			log.Printf("%v command timed out after %v, drone connection degraded", name, p.timeout)
+		}
+		return fmt.Errorf("%v: %w", name, ctx.Err())
+	}
+}
+
+// do sends a command that takes no value.
+func (p *pilot) do(name string, f func() error) error {
+	p.log.record("command", name)
+	return p.send(name, f)
+}
+
+// set runs f, a movement command, straight away. These only change what
+// the driver sends the drone every 50ms of its own accord, so they never
+// wait on BLE and need no timeout.
+func (p *pilot) set(name string, f func() error) error {
+	p.metrics.command(name)
+	return f()
+}
+
+// commandRate returns the movement commands sent in the last second and whether
+// any were dropped by the rate limit.
+func (p *pilot) commandRate() (int, bool) {
+	return p.rate.stats()
+}
+
+// commands returns the last value passed for each axis, whether or not it
+// was sent, as pitch (forward), roll (right), throttle (up) and yaw
+// (clockwise), negative for the other way.
+func (p *pilot) commands() [4]int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.axes
+}
+
+// moveAxis sends a movement command that sets axis to signed. The
+// control loops repeat these every tick, so only changes on the axis are
+// recorded, and repeats are the ones dropped when over the rate limit.
+func (p *pilot) moveAxis(axis, signed int, name string, val int, f func(int) error) error {
+	p.Lock()
+	changed := p.axes[axis] != signed
+	p.axes[axis] = signed
+	p.Unlock()
+
+	if !p.rate.allow(time.Now(), changed) {
+		return nil
+	}
+	if changed {
+		p.log.record("command", "%v %d", name, val)
+	}
+	return p.set(name, func() error { return f(val) })
+}
+
+// moveFlat is moveAxis for pitch and roll, positive for forward and right,
+// which the fence limits together: signed is what is asked for on axis,
+// and what is sent is what the fence lets through of it along with what
+// was last asked for on the other.
+func (p *pilot) moveFlat(axis, signed int) error {
+	p.Lock()
+	p.asked[axis] = signed
+	pitch, roll := p.asked[pitchAxis], p.asked[rollAxis]
+	p.Unlock()
+
+	pitch, roll = p.fence.limit(pitch, roll)
+	switch {
+	case axis == pitchAxis && pitch < 0:
+		return p.moveAxis(pitchAxis, pitch, "backward", -pitch, p.drone.Backward)
+	case axis == pitchAxis:
+		return p.moveAxis(pitchAxis, pitch, "forward", pitch, p.drone.Forward)
+	case roll < 0:
+		return p.moveAxis(rollAxis, roll, "left", -roll, p.drone.Left)
+	default:
+		return p.moveAxis(rollAxis, roll, "right", roll, p.drone.Right)
+	}
+}
+
+// holdFence sends the pitch and roll last asked for again, as limited by
+// the fence from where the drone is now, for commands that are held
+// rather than sent every tick, such as those of a demo script.
+func (p *pilot) holdFence() {
+	if p.fence == nil {
+		return
+	}
+	p.Lock()
+	asked := p.asked
+	p.Unlock()
+
+	if asked[pitchAxis] != 0 {
+		p.moveFlat(pitchAxis, asked[pitchAxis])
+	}
+	if asked[rollAxis] != 0 {
+		p.moveFlat(rollAxis, asked[rollAxis])
+	}
+}
+
+func (p *pilot) TakeOff() error   { return p.do("takeoff", p.drone.TakeOff) }
+func (p *pilot) Land() error      { return p.do("land", p.drone.Land) }
+func (p *pilot) Emergency() error { return p.do("emergency", p.drone.Emergency) }
+func (p *pilot) FlatTrim() error  { return p.do("flat trim", p.drone.FlatTrim) }
+
+// Stop hovers, so every axis is taken as zero from then on: by the
+// altimeter, by the track reading commands, and by holdFence, which would
+// otherwise send the pitch and roll asked for again.
+func (p *pilot) Stop() error {
+	p.Lock()
+	p.axes = [4]int{}
+	p.asked = [2]int{}
+	p.Unlock()
+	p.alt.throttle(0, time.Now())
+	p.log.record("command", "stop")
+	return p.set("stop", p.drone.Stop)
+}
+
+func (p *pilot) HullProtection(protect bool) error {
+	return p.do("hull protection", func() error { return p.drone.HullProtection(protect) })
+}
+
+func (p *pilot) Lights(a lightAnimation) error {
+	return p.do("lights "+a.name, func() error { return p.drone.LightControl(0, a.mode, a.intensity) })
+}
+
+// Every pitch and roll is limited by the fence here, whether it comes from
+// the sticks, a demo script or one of the automatic modes.
+func (p *pilot) Forward(val int) error  { return p.moveFlat(pitchAxis, val) }
+func (p *pilot) Backward(val int) error { return p.moveFlat(pitchAxis, -val) }
+func (p *pilot) Right(val int) error    { return p.moveFlat(rollAxis, val) }
+func (p *pilot) Left(val int) error     { return p.moveFlat(rollAxis, -val) }
+
+func (p *pilot) Up(val int) error {
+	p.alt.throttle(val, time.Now())
+	return p.moveAxis(throttleAxis, val, "up", val, p.drone.Up)
+}
+
+func (p *pilot) Down(val int) error {
+	p.alt.throttle(-val, time.Now())
+	return p.moveAxis(throttleAxis, -val, "down", val, p.drone.Down)
+}
+
+// Every turn is capped to -max-yaw-rate here, whether it comes from the
+// sticks, the keyboard or one of the automatic modes.
+func (p *pilot) Clockwise(val int) error {
+	val = capYaw(val)
+	return p.moveAxis(yawAxis, val, "clockwise", val, p.drone.Clockwise)
+}
+
+func (p *pilot) CounterClockwise(val int) error {
+	val = capYaw(val)
+	return p.moveAxis(yawAxis, -val, "counter clockwise", val, p.drone.CounterClockwise)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var modeFlag = flag.String("mode", "", "command shaping preset to start in: sport, beginner or cinematic (default beginner if -max-command is below 100, otherwise sport)")
+
+// preset is a named set of command shaping settings.
+type preset struct {
+	name string
+	// limit caps every command, in percent.
+	limit int
+	// expo softens the response around center stick, from 0 for linear
+	// to 1 for fully cubic.
+	expo float64
+	// slew is how fast the sticks are allowed to move, in full stick
+	// travels per second, or 0 for no limit.
+	slew float64
+}
+
+// presets are the command shaping presets the power button cycles through.
+// Beginner is capped at -max-command, and cinematic damps everything for
+// smooth video. They can be tuned in flight from the menu, so they are
+// guarded by presetsMu along with the index of the one in use.
+var (
+	presetsMu     sync.Mutex
+	presets       []preset
+	currentPreset int
+)
+
+// setupPresets fills in the presets and selects the one named by mode.
+func setupPresets(mode string, maxCommand int) error {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	presets = []preset{
+		{name: "sport", limit: 100},
+		{name: "beginner", limit: maxCommand},
+		{name: "cinematic", limit: 40, expo: 0.7, slew: 0.5},
+	}
+
+	if mode == "" {
+		mode = "sport"
+		if maxCommand < 100 {
+			mode = "beginner"
+		}
+	}
+	for i, p := range presets {
+		if p.name == mode {
+			currentPreset = i
+			return nil
+		}
+	}
+
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		names[i] = p.name
+	}
+	return fmt.Errorf("unknown mode %q, expected one of %v", mode, strings.Join(names, ", "))
+}
+
+// shaping returns the preset in use.
+func shaping() preset {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	return presets[currentPreset]
+}
+
+// nextPreset switches to the next preset.
+func nextPreset() {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	currentPreset = (currentPreset + 1) % len(presets)
+}
+
+// tunePreset changes the preset in use with f.
+func tunePreset(f func(p *preset)) {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	f(&presets[currentPreset])
+}
+
+// applyExpo curves a stick position by expo, keeping full stick at full
+// command but making small movements gentler.
+func applyExpo(val, expo float64) float64 {
+	x := val / controls.Offset
+	return ((1-expo)*x + expo*math.Pow(x, 3)) * controls.Offset
+}
+
+// slewed is a stick axis that follows the real stick no faster than the
+// slew rate of the preset in use.
+type slewed struct {
+	sync.Mutex
+	pos float64
+	at  time.Time
+}
+
+var slewLeftX, slewLeftY, slewRightX, slewRightY slewed
+
+// follow moves towards target, by at most rate full stick travels per
+// second since the last call, and returns the new position.
+func (s *slewed) follow(target, rate float64, now time.Time) float64 {
+	s.Lock()
+	defer s.Unlock()
+
+	if rate <= 0 || s.at.IsZero() {
+		s.pos = target
+	} else {
+		step := rate * 2 * controls.Offset * now.Sub(s.at).Seconds()
+		s.pos += math.Max(-step, math.Min(step, target-s.pos))
+	}
+	s.at = now
+	return s.pos
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	blurFaces   = flag.String("blur-faces", "", "Haar cascade file, such as haarcascade_frontalface_default.xml, used to find and blur faces in recordings and saved frames")
+	windowFaces = flag.Bool("window-faces", false, "with -blur-faces, leave faces unblurred in the window and only blur what is saved")
+)
+
+// faceBlur finds faces in each frame and blurs them out of whatever is
+// saved, so that bystanders at a public event are not recorded. It is nil
+// when -blur-faces is not set, and its methods then do nothing.
+type faceBlur struct {
+	cascade gocv.CascadeClassifier
+	faces   []image.Rectangle
+}
+
+// newFaceBlur loads the face detector from a Haar cascade file, returning
+// nil if there is none.
+func newFaceBlur(cascade string) (*faceBlur, error) {
+	if cascade == "" {
+		return nil, nil
+	}
+	f := &faceBlur{cascade: gocv.NewCascadeClassifier()}
+	if !f.cascade.Load(cascade) {
+		f.cascade.Close()
+		return nil, fmt.Errorf("cannot load face cascade %v", cascade)
+	}
+	return f, nil
+}
+
+// Close releases the face detector.
+func (f *faceBlur) Close() error {
+	if f == nil {
+		return nil
+	}
+	return f.cascade.Close()
+}
+
+// detect finds the faces in img, which blur and blurred then hide until
+// the next call.
+func (f *faceBlur) detect(img gocv.Mat) {
+	if f == nil {
+		return
+	}
+	f.faces = f.cascade.DetectMultiScale(img)
+}
+
+// blur blurs the faces found by detect in img itself.
+func (f *faceBlur) blur(img *gocv.Mat) {
+	if f == nil {
+		return
+	}
+	whole := image.Rect(0, 0, img.Cols(), img.Rows())
+	for _, r := range f.faces {
+		r = r.Intersect(whole)
+		if r.Empty() {
+			continue
+		}
+
+		// blur harder for bigger faces, so that they cannot be made out
+		k := r.Dx()/3 | 1
+		face := img.Region(r)
+		gocv.GaussianBlur(face, &face, image.Pt(k, k), 0, 0, gocv.BorderDefault)
+		face.Close()
+	}
+}
+
+// blurred returns img ready to be saved: img itself when the faces are
+// already blurred in it, or otherwise a copy with them blurred, which the
+// caller must close. closeIt reports which.
+func (f *faceBlur) blurred(img gocv.Mat) (out gocv.Mat, closeIt bool) {
+	if f == nil || len(f.faces) == 0 || !*windowFaces {
+		return img, false
+	}
+	out = img.Clone()
+	f.blur(&out)
+	return out, true
+}
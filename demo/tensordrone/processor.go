@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"sync"
+
+	"gobot.io/x/gobot"
+	"gocv.io/x/gocv"
+)
+
+var publish = flag.Bool("publish", false, "publish every classification as a gobot event for other devices and robots to react to")
+
+// ClassificationEvent is the name of the event published on Classifications.
+const ClassificationEvent = "classification"
+
+// Classifications publishes a ClassificationResult each time a frame is
+// classified, when -publish is set. Subscribe to it with
+//
+//	Classifications.On(ClassificationEvent, func(data interface{}) {
+//		result := data.(ClassificationResult)
+//		...
+//	})
+var Classifications = newClassifications()
+
+func newClassifications() gobot.Eventer {
+	e := gobot.NewEventer()
+	e.AddEvent(ClassificationEvent)
+	return e
+}
+
+// publishClassification sends result to any subscribers of Classifications.
+func publishClassification(result ClassificationResult) {
+	if *publish {
+		Classifications.Publish(ClassificationEvent, result)
+	}
+}
+
+// ClassificationResult is what the classifier made of a frame.
+type ClassificationResult struct {
+	// Label is the most probable description of the frame.
+	Label string
+	// Score is how probable the label is.
+	Score float32
+	// OK is false when the frame was not classified, such as when inference
+	// failed or is only run on demand.
+	OK bool
+}
+
+// FrameProcessor does extra work on each camera frame once it has been
+// classified, such as detecting things in it or drawing on it.
+type FrameProcessor interface {
+	Process(img gocv.Mat, result ClassificationResult)
+}
+
+// FrameProcessorFunc lets an ordinary function be used as a FrameProcessor.
+type FrameProcessorFunc func(img gocv.Mat, result ClassificationResult)
+
+// Process calls f(img, result).
+func (f FrameProcessorFunc) Process(img gocv.Mat, result ClassificationResult) {
+	f(img, result)
+}
+
+var (
+	processorsMu    sync.Mutex
+	frameProcessors []FrameProcessor
+)
+
+// RegisterFrameProcessor adds p to the processors run on every frame. To
+// add your own processing without changing the rest of the demo, put it in
+// a new file in this package and register it from an init function.
+// Processors run in the order they are registered, each seeing anything
+// drawn by the ones before it.
+func RegisterFrameProcessor(p FrameProcessor) {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+
+	frameProcessors = append(frameProcessors, p)
+}
+
+// processFrame runs every registered processor on img.
+func processFrame(img gocv.Mat, result ClassificationResult) {
+	processorsMu.Lock()
+	processors := frameProcessors
+	processorsMu.Unlock()
+
+	for _, p := range processors {
+		p.Process(img, result)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	profileName = flag.String("profile", "", "load the tuning flags saved under this name, with flags given on the command line taking precedence")
+	saveProfile = flag.String("save-profile", "", "save the tuning flags as given, including any -profile, under this name and exit")
+)
+
+// tuningFlags are the flags saved in a profile. They are the ones read as
+// the demo runs, so that a profile can also be switched to from the menu.
+var tuningFlags = []string{
+	"mode", "max-command", "deadzone",
+	"hover-settle", "settle-power", "settle-time",
+	"min-altitude", "max-altitude", "climb-rate",
+	"guard-altitude", "guard-min",
+	"battery-compensate", "battery-start", "battery-empty", "battery-smoothing",
+	"orbit-speed", "orbit-turn", "max-yaw-rate", "yaw-rate",
+	"hull", "swap-rb", "crop", "batch", "audio-confidence",
+	"auto-orient", "orient-ccw", "text-color", "text-thickness",
+}
+
+// profileDir is where profiles are kept, in the user config directory.
+func profileDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fosdem-drone", "profiles"), nil
+}
+
+// profiles returns the names of the saved profiles.
+func profiles() []string {
+	dir, err := profileDir()
+	if err != nil {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeProfile saves the current value of every tuning flag as name.
+func writeProfile(name string) (string, error) {
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	values := make(map[string]string, len(tuningFlags))
+	for _, f := range tuningFlags {
+		values[f] = flag.Lookup(f).Value.String()
+	}
+	b, err := json.MarshalIndent(values, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".json")
+	return path, ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// loadProfile sets the tuning flags saved as name, leaving alone those in
+// keep, which are the ones given on the command line.
+func loadProfile(name string, keep map[string]bool) error {
+	dir, err := profileDir()
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return fmt.Errorf("profile %v: %v", name, err)
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("profile %v: %v", name, err)
+	}
+
+	tuning := make(map[string]bool, len(tuningFlags))
+	for _, f := range tuningFlags {
+		tuning[f] = true
+	}
+	for f, v := range values {
+		if !tuning[f] {
+			return fmt.Errorf("profile %v: %v is not a tuning flag", name, f)
+		}
+		if keep[f] {
+			continue
+		}
+		if err := flag.Set(f, v); err != nil {
+			return fmt.Errorf("profile %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// commandLineFlags returns the names of the flags given on the command line.
+func commandLineFlags() map[string]bool {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	return given
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+var qrCodes = flag.Bool("qr", false, "detect and decode QR codes in the video")
+
+// qrProcessor is a FrameProcessor that outlines any QR code in the frame
+// and shows what it says.
+type qrProcessor struct {
+	detector gocv.QRCodeDetector
+	points   gocv.Mat
+	straight gocv.Mat
+}
+
+func newQRProcessor() *qrProcessor {
+	return &qrProcessor{
+		detector: gocv.NewQRCodeDetector(),
+		points:   gocv.NewMat(),
+		straight: gocv.NewMat(),
+	}
+}
+
+// Close releases the detector.
+func (q *qrProcessor) Close() error {
+	q.straight.Close()
+	q.points.Close()
+	return q.detector.Close()
+}
+
+// Process implements FrameProcessor.
+func (q *qrProcessor) Process(img gocv.Mat, result ClassificationResult) {
+	text := q.detector.DetectAndDecode(img, &q.points, &q.straight)
+	if q.points.Empty() {
+		return
+	}
+
+	// the corners come back as a row of float x,y pairs
+	var corners []image.Point
+	for i := 0; i < q.points.Cols(); i++ {
+		v := q.points.GetVecfAt(0, i)
+		if len(v) < 2 {
+			return
+		}
+		corners = append(corners, image.Pt(int(v[0]), int(v[1])))
+	}
+	if len(corners) == 0 {
+		return
+	}
+
+	c := color.RGBA{255, 0, 255, 0}
+	for i := range corners {
+		gocv.Line(&img, corners[i], corners[(i+1)%len(corners)], c, 2)
+	}
+	if text != "" {
+		gocv.PutText(&img, text, corners[0].Add(image.Pt(0, -10)), overlayFont, overlayScale, c, overlayThickness)
+	}
+}
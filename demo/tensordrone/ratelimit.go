@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+)
+
+var maxRate = flag.Int("max-rate", 0, "most movement commands to send each second, dropping repeats of the same value beyond that (0 for no limit)")
+
+// rateLimiter counts the commands sent each second and, once there is a
+// limit, drops commands over it.
+type rateLimiter struct {
+	sync.Mutex
+	limit int
+
+	window  time.Time
+	sent    int
+	dropped int
+
+	// the counts for the last full second
+	lastSent    int
+	lastDropped int
+}
+
+// allow reports whether a command may be sent at now. Commands that must
+// get through, such as a change in value, are counted but never dropped.
+func (r *rateLimiter) allow(now time.Time, must bool) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if now.Sub(r.window) >= time.Second {
+		r.lastSent, r.lastDropped = r.sent, r.dropped
+		r.sent, r.dropped = 0, 0
+		r.window = now
+	}
+
+	if !must && r.limit > 0 && r.sent >= r.limit {
+		r.dropped++
+		return false
+	}
+	r.sent++
+	return true
+}
+
+// stats returns how many commands were sent in the last second, and
+// whether any were dropped.
+func (r *rateLimiter) stats() (perSecond int, limiting bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.lastSent, r.lastDropped > 0
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	recordDir     = flag.String("record", "", "write every frame, overlay and all, to a video in this directory, with a JPEG snapshot whenever the -snapshot-label is seen")
+	snapshotLabel = flag.String("snapshot-label", "", "label that saves a snapshot to the -record directory when its score crosses -snapshot-score")
+	snapshotScore = flag.Float64("snapshot-score", 0.8, "score the -snapshot-label must reach for a snapshot")
+)
+
+const (
+	// recordFPS is the frame rate written into the recording. Frames are
+	// written as they arrive, so it plays back at about the speed it was
+	// recorded at.
+	recordFPS = 15
+	// recordQueue is how many frames can wait to be written before frames
+	// are left out of the recording, so that a slow disk never holds up
+	// the camera or the display.
+	recordQueue = 30
+)
+
+// recordedFrame is a copy of a frame waiting to be written, with the name
+// of the snapshot to save it as, if it is one.
+type recordedFrame struct {
+	img      gocv.Mat
+	snapshot string
+}
+
+// recorder writes the annotated frames to a video, and those in which the
+// snapshot label crosses its score to JPEGs, from a goroutine of its own.
+// It is nil when there is no -record, and then copies nothing.
+type recorder struct {
+	dir   string
+	label string
+	score float32
+
+	// above is whether the label was over the score in the last frame
+	// classified, so that a snapshot is only saved as it crosses
+	above   bool
+	dropped int32
+
+	frames chan recordedFrame
+	wg     sync.WaitGroup
+
+	// the rest belong to the writing goroutine
+	video  *gocv.VideoWriter
+	path   string
+	size   image.Point
+	sized  gocv.Mat
+	count  int
+	failed bool
+}
+
+func newRecorder(dir, label string, score float64) (*recorder, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	r := &recorder{
+		dir:    dir,
+		label:  label,
+		score:  float32(score),
+		frames: make(chan recordedFrame, recordQueue),
+		sized:  gocv.NewMat(),
+	}
+	r.wg.Add(1)
+	go r.write()
+	return r, nil
+}
+
+// capture queues a copy of img, the frame as annotated, to be recorded,
+// along with a snapshot if label has just crossed the snapshot score. If
+// the queue is full the frame is left out rather than waited for.
+func (r *recorder) capture(img gocv.Mat, label string, score float32, classified bool, now time.Time) {
+	if r == nil {
+		return
+	}
+	var snapshot string
+	if classified && r.label != "" {
+		above := label == r.label && score >= r.score
+		if above && !r.above {
+			snapshot = fmt.Sprintf("%v-%v.jpg", label, now.Format("20060102-150405.000"))
+		}
+		r.above = above
+	}
+
+	frame := recordedFrame{img: img.Clone(), snapshot: snapshot}
+	select {
+	case r.frames <- frame:
+	default:
+		frame.img.Close()
+		if atomic.AddInt32(&r.dropped, 1) == 1 {
+			log.Println("record: the disk is not keeping up, leaving frames out")
+		}
+	}
+}
+
+// write writes the queued frames until the recorder is closed. The video
+// is opened at the size of the first frame, and named for when it was.
+func (r *recorder) write() {
+	defer r.wg.Done()
+
+	for frame := range r.frames {
+		if frame.snapshot != "" {
+			name := filepath.Join(r.dir, frame.snapshot)
+			if !gocv.IMWrite(name, frame.img) {
+				log.Printf("record: cannot write %v", name)
+			}
+		}
+		r.writeFrame(frame.img)
+		frame.img.Close()
+	}
+}
+
+// writeFrame adds img to the video, scaling it to the size of the first
+// frame if it is not.
+func (r *recorder) writeFrame(img gocv.Mat) {
+	if r.failed {
+		return
+	}
+	if r.video == nil {
+		r.path = filepath.Join(r.dir, time.Now().Format("20060102-150405")+".avi")
+		video, err := gocv.VideoWriterFile(r.path, "MJPG", recordFPS, img.Cols(), img.Rows(), true)
+		if err != nil {
+			log.Println("record:", err)
+			r.failed = true
+			return
+		}
+		r.video, r.size = video, image.Pt(img.Cols(), img.Rows())
+	}
+
+	frame := img
+	if img.Cols() != r.size.X || img.Rows() != r.size.Y {
+		gocv.Resize(img, &r.sized, r.size, 0, 0, gocv.InterpolationArea)
+		frame = r.sized
+	}
+	if err := r.video.Write(frame); err != nil {
+		log.Println("record:", err)
+		return
+	}
+	r.count++
+}
+
+// Close writes the frames still queued and finishes the video. capture
+// must not be called afterwards.
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	close(r.frames)
+	r.wg.Wait()
+
+	r.sized.Close()
+	if dropped := atomic.LoadInt32(&r.dropped); dropped > 0 {
+		log.Printf("record: left %d frames out", dropped)
+	}
+	if r.video == nil {
+		return nil
+	}
+	log.Printf("record: wrote %d frames to %v", r.count, r.path)
+	return r.video.Close()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := newRecorder(dir, "cat", 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is written until the first frame gives the video its size
+	if videos, _ := filepath.Glob(filepath.Join(dir, "*.avi")); len(videos) != 0 {
+		t.Fatalf("video %v created before any frame", videos)
+	}
+
+	img := gocv.NewMatWithSize(48, 64, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	wide := gocv.NewMatWithSize(48, 128, gocv.MatTypeCV8UC3)
+	defer wide.Close()
+
+	frames := []struct {
+		img        gocv.Mat
+		label      string
+		score      float32
+		classified bool
+	}{
+		{img, "dog", 0.9, true},
+		{img, "cat", 0.4, true},
+		// crosses, a snapshot
+		{img, "cat", 0.6, true},
+		// still above, no snapshot
+		{img, "cat", 0.7, true},
+		{wide, "", 0, false},
+		{img, "cat", 0.3, true},
+		// crosses again, another snapshot
+		{img, "cat", 0.8, true},
+	}
+	start := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+	for i, f := range frames {
+		r.capture(f.img, f.label, f.score, f.classified, start.Add(time.Duration(i)*time.Second))
+	}
+
+	// closing writes all of the queued frames before finishing the video
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.count != len(frames) {
+		t.Errorf("wrote %d frames, want %d", r.count, len(frames))
+	}
+	if r.size != image.Pt(64, 48) {
+		t.Errorf("video is %v, want the size of the first frame", r.size)
+	}
+	if videos, _ := filepath.Glob(filepath.Join(dir, "*.avi")); len(videos) != 1 {
+		t.Errorf("videos %v, want one", videos)
+	}
+
+	snapshots, _ := filepath.Glob(filepath.Join(dir, "*.jpg"))
+	want := []string{
+		filepath.Join(dir, "cat-20180203-100002.000.jpg"),
+		filepath.Join(dir, "cat-20180203-100006.000.jpg"),
+	}
+	if len(snapshots) != len(want) {
+		t.Fatalf("snapshots %v, want %v", snapshots, want)
+	}
+	for i := range want {
+		if snapshots[i] != want[i] {
+			t.Errorf("snapshot %v, want %v", snapshots[i], want[i])
+		}
+	}
+}
+
+func TestRecorderOff(t *testing.T) {
+	r, err := newRecorder("", "cat", 0.5)
+	if err != nil || r != nil {
+		t.Fatalf("got %v, %v without a directory", r, err)
+	}
+	// a nil recorder takes frames and closes without doing anything
+	r.capture(gocv.Mat{}, "cat", 1, true, time.Now())
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var watchModel = flag.Bool("watch-model", false, "reload the model and descriptions whenever either file changes on disk")
+
+// modelReloader loads a new classifier from the model and descriptions
+// files in the background, and hands it over to the frame callback to
+// swap in between frames, so that a frame being classified is never left
+// with a closed network.
+type modelReloader struct {
+	model, descriptions string
+	backend, target     string
+
+	busy  int32
+	ready chan *classifier
+
+	// loaded is the newest modification time of the files when they
+	// were last loaded, for watching them
+	loaded atomic.Value
+}
+
+func newModelReloader(model, descriptions, backend, target string) *modelReloader {
+	r := &modelReloader{
+		model:        model,
+		descriptions: descriptions,
+		backend:      backend,
+		target:       target,
+		ready:        make(chan *classifier, 1),
+	}
+	r.loaded.Store(r.modified())
+	return r
+}
+
+// reload starts loading the model again, unless it is already being
+// loaded. A model that fails to load or check is logged and the one in use
+// is kept.
+func (r *modelReloader) reload() {
+	if !atomic.CompareAndSwapInt32(&r.busy, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&r.busy, 0)
+
+		r.loaded.Store(r.modified())
+		descriptions, err := readDescriptions(r.descriptions)
+		if err != nil {
+			log.Println("reload:", err)
+			return
+		}
+		cls, err := newClassifier(r.model, descriptions, r.backend, r.target)
+		if err != nil {
+			log.Println("reload:", err)
+			return
+		}
+		if err := cls.check(); err != nil {
+			log.Println("reload:", err)
+			cls.Close()
+			return
+		}
+
+		// replace any loaded one that has not been swapped in yet
+		select {
+		case old := <-r.ready:
+			old.Close()
+		default:
+		}
+		r.ready <- cls
+		log.Printf("reloaded %v with %d descriptions", r.model, len(descriptions))
+	}()
+}
+
+// swap returns the newly loaded classifier, closing cls, if there is one,
+// and cls otherwise. Call it between frames, from the goroutine that uses
+// the classifier.
+func (r *modelReloader) swap(cls *classifier) *classifier {
+	select {
+	case next := <-r.ready:
+		cls.Close()
+		return next
+	default:
+		return cls
+	}
+}
+
+// watch reloads the model if either file has changed since it was loaded.
+func (r *modelReloader) watch() {
+	if r.modified().After(r.loaded.Load().(time.Time)) {
+		r.reload()
+	}
+}
+
+// modified returns the newest modification time of the model, its
+// -model-config, if any, and descriptions files.
+func (r *modelReloader) modified() time.Time {
+	var newest time.Time
+	for _, path := range []string{r.model, *modelConfig, r.descriptions} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest
+}
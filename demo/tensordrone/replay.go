@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gobot.io/x/gobot"
+)
+
+var replayFile = flag.String("replay", "", "fly from a script of joystick events instead of a joystick")
+
+// joystickSource is where joystick events come from, either a joystick or
+// a script of its events.
+type joystickSource interface {
+	gobot.Device
+	gobot.Eventer
+}
+
+// scriptedEvent is one joystick event in a replay script.
+type scriptedEvent struct {
+	at    time.Duration
+	name  string
+	value interface{}
+}
+
+// readScript reads a replay script from a file. See parseScript.
+func readScript(path string) ([]scriptedEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseScript(f)
+}
+
+// parseScript reads a replay script, which has one joystick event per line
+// as the time after starting to send it, the event name, and for an axis
+// its value:
+//
+//	0s r1_press
+//	1s triangle_press
+//	3s right_y -20000
+//	4.5s right_y 0
+//
+// Blank lines and lines starting with # are ignored. The events are
+// returned in the order they are sent.
+func parseScript(r io.Reader) ([]scriptedEvent, error) {
+	var events []scriptedEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected a time, an event and an optional axis value", line)
+		}
+		at, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		e := scriptedEvent{at: at, name: fields[1]}
+		if len(fields) == 3 {
+			val, err := strconv.ParseInt(fields[2], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			e.value = int16(val)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].at < events[j].at
+	})
+	return events, nil
+}
+
+// scriptedStick publishes the events of a replay script in place of a
+// joystick, so that the same handlers fly the drone.
+type scriptedStick struct {
+	name   string
+	events []scriptedEvent
+	halt   chan bool
+	gobot.Eventer
+
+	// wait pauses for d, returning false if the replay should stop. It
+	// can be replaced to step through a script without waiting.
+	wait func(d time.Duration) bool
+}
+
+func newScriptedStick(events []scriptedEvent) *scriptedStick {
+	s := &scriptedStick{
+		name:    gobot.DefaultName("Replay"),
+		events:  events,
+		halt:    make(chan bool),
+		Eventer: gobot.NewEventer(),
+	}
+	s.wait = func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-s.halt:
+			return false
+		}
+	}
+	for _, e := range events {
+		s.AddEvent(e.name)
+	}
+	return s
+}
+
+// Name returns the drivers name.
+func (s *scriptedStick) Name() string { return s.name }
+
+// SetName sets the drivers name.
+func (s *scriptedStick) SetName(n string) { s.name = n }
+
+// Connection returns nil, as the script needs no connection.
+func (s *scriptedStick) Connection() gobot.Connection { return nil }
+
+// Start begins replaying the script.
+func (s *scriptedStick) Start() error {
+	go s.play()
+	return nil
+}
+
+// Halt stops the replay if it is still running.
+func (s *scriptedStick) Halt() error {
+	close(s.halt)
+	return nil
+}
+
+// play publishes each event once its time has come.
+func (s *scriptedStick) play() {
+	var now time.Duration
+	for _, e := range s.events {
+		if !s.wait(e.at - now) {
+			return
+		}
+		now = e.at
+		s.Publish(e.name, e.value)
+	}
+	log.Println("replay finished")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDrone records the commands it is sent, with their values.
+type fakeDrone struct {
+	sync.Mutex
+	sent []string
+}
+
+func (d *fakeDrone) send(format string, args ...interface{}) error {
+	d.Lock()
+	defer d.Unlock()
+	d.sent = append(d.sent, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (d *fakeDrone) commands() []string {
+	d.Lock()
+	defer d.Unlock()
+	return append([]string(nil), d.sent...)
+}
+
+func (d *fakeDrone) TakeOff() error   { return d.send("takeoff") }
+func (d *fakeDrone) Land() error      { return d.send("land") }
+func (d *fakeDrone) Stop() error      { return d.send("stop") }
+func (d *fakeDrone) Emergency() error { return d.send("emergency") }
+func (d *fakeDrone) FlatTrim() error  { return d.send("flat trim") }
+
+func (d *fakeDrone) HullProtection(protect bool) error {
+	return d.send("hull protection %v", protect)
+}
+
+func (d *fakeDrone) LightControl(id uint8, mode uint8, intensity uint8) error {
+	return d.send("lights %d %d %d", id, mode, intensity)
+}
+
+func (d *fakeDrone) Forward(val int) error          { return d.send("forward %d", val) }
+func (d *fakeDrone) Backward(val int) error         { return d.send("backward %d", val) }
+func (d *fakeDrone) Right(val int) error            { return d.send("right %d", val) }
+func (d *fakeDrone) Left(val int) error             { return d.send("left %d", val) }
+func (d *fakeDrone) Up(val int) error               { return d.send("up %d", val) }
+func (d *fakeDrone) Down(val int) error             { return d.send("down %d", val) }
+func (d *fakeDrone) Clockwise(val int) error        { return d.send("clockwise %d", val) }
+func (d *fakeDrone) CounterClockwise(val int) error { return d.send("counterclockwise %d", val) }
+
+func TestReplay(t *testing.T) {
+	events, err := parseScript(strings.NewReader(`
+# take off, trim, press down while the menu is open, then stop and land
+0s triangle_press
+1s up_press
+1.5s down_press
+3s x_press
+3s square_press
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drone := &fakeDrone{}
+	p := newPilot(drone, nil, nil, newAltimeter(0), nil, time.Second, 0)
+
+	// handled is sent on once each press has been handled, so that the
+	// next is only published after it
+	handled := make(chan struct{}, len(events))
+	act := func(f func() error) func() {
+		return func() {
+			f()
+			handled <- struct{}{}
+		}
+	}
+	actions := map[string]func(){
+		"takeoff": act(p.TakeOff),
+		"trim":    act(p.FlatTrim),
+		"lights":  act(func() error { return p.Lights(lightAnimations[0]) }),
+		"stop":    act(p.Stop),
+		"land":    act(p.Land),
+	}
+	// the menu is open for down, and takes the press
+	menu := func(button string) bool {
+		if button != "down" {
+			return false
+		}
+		handled <- struct{}{}
+		return true
+	}
+
+	s := newScriptedStick(events)
+	onButtons(s, defaultBindings, actions, menu)
+
+	var waits []time.Duration
+	next := func() {
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatal("a press was not handled")
+		}
+	}
+	s.wait = func(d time.Duration) bool {
+		if len(waits) > 0 {
+			next()
+		}
+		waits = append(waits, d)
+		return true
+	}
+	s.play()
+	next()
+
+	wantWaits := []time.Duration{0, time.Second, 500 * time.Millisecond, 1500 * time.Millisecond, 0}
+	if !reflect.DeepEqual(waits, wantWaits) {
+		t.Errorf("waited %v, want %v", waits, wantWaits)
+	}
+	want := []string{"takeoff", "flat trim", "land", "stop"}
+	if got := drone.commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
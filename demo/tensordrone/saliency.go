@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+var salient = flag.Bool("salient", false, "classify only the part of each frame that stands out the most, rather than the whole frame")
+
+const (
+	// saliencySize is the side of the square that frames are shrunk to
+	// before finding what stands out, as the spectral residual method
+	// picks out objects best at around this scale.
+	saliencySize = 64
+	// saliencyMin is the smallest fraction of the frame, on each side,
+	// that is classified, so that the model still has some context.
+	saliencyMin = 0.25
+)
+
+// saliencyDetector finds the region of each frame that stands out the
+// most. OpenCV's saliency module is not wrapped by gocv, so this is the
+// same spectral residual method as its StaticSaliencySpectralResidual,
+// built from the core functions: the parts of the frame's log spectrum
+// that a smoothed spectrum does not explain are what stand out.
+type saliencyDetector struct {
+	gray    gocv.Mat
+	small   gocv.Mat
+	real    gocv.Mat
+	complex gocv.Mat
+	mag     gocv.Mat
+	angle   gocv.Mat
+	smooth  gocv.Mat
+	mask    gocv.Mat
+}
+
+func newSaliencyDetector() *saliencyDetector {
+	return &saliencyDetector{
+		gray:    gocv.NewMat(),
+		small:   gocv.NewMat(),
+		real:    gocv.NewMat(),
+		complex: gocv.NewMat(),
+		mag:     gocv.NewMat(),
+		angle:   gocv.NewMat(),
+		smooth:  gocv.NewMat(),
+		mask:    gocv.NewMat(),
+	}
+}
+
+// Close releases the working images.
+func (s *saliencyDetector) Close() error {
+	s.mask.Close()
+	s.smooth.Close()
+	s.angle.Close()
+	s.mag.Close()
+	s.complex.Close()
+	s.real.Close()
+	s.small.Close()
+	return s.gray.Close()
+}
+
+// region returns the part of img that stands out the most: the bounding
+// box of the largest salient blob, grown to at least saliencyMin of the
+// frame on each side.
+func (s *saliencyDetector) region(img gocv.Mat) image.Rectangle {
+	whole := image.Rect(0, 0, img.Cols(), img.Rows())
+
+	gocv.CvtColor(img, &s.gray, gocv.ColorBGRToGray)
+	gocv.Resize(s.gray, &s.small, image.Pt(saliencySize, saliencySize), 0, 0, gocv.InterpolationArea)
+	s.small.ConvertToWithParams(&s.real, gocv.MatTypeCV32F, 1.0/255, 0)
+
+	// the spectrum of the frame, as amplitude and phase
+	zeros := gocv.Zeros(saliencySize, saliencySize, gocv.MatTypeCV32F)
+	defer zeros.Close()
+	gocv.Merge([]gocv.Mat{s.real, zeros}, &s.complex)
+	gocv.DFT(s.complex, &s.complex, 0)
+	s.cartToPolar()
+
+	// the spectral residual is the log amplitude less its local average
+	s.mag.AddFloat(1e-9)
+	gocv.Log(s.mag, &s.mag)
+	gocv.Blur(s.mag, &s.smooth, image.Pt(3, 3))
+	gocv.Subtract(s.mag, s.smooth, &s.mag)
+	gocv.Exp(s.mag, &s.mag)
+
+	// back to an image, whose energy is the saliency map
+	re, im := gocv.NewMat(), gocv.NewMat()
+	defer re.Close()
+	defer im.Close()
+	gocv.PolarToCart(s.mag, s.angle, &re, &im, false)
+	gocv.Merge([]gocv.Mat{re, im}, &s.complex)
+	gocv.DFT(s.complex, &s.complex, gocv.DftInverse|gocv.DftScale)
+	s.cartToPolar()
+	gocv.Multiply(s.mag, s.mag, &s.mag)
+	gocv.GaussianBlur(s.mag, &s.smooth, image.Pt(5, 5), 8, 8, gocv.BorderDefault)
+	gocv.Normalize(s.smooth, &s.smooth, 0, 255, gocv.NormMinMax)
+
+	// the blobs well above the average saliency
+	mean := s.smooth.Mean().Val1
+	gocv.Threshold(s.smooth, &s.smooth, float32(3*mean), 255, gocv.ThresholdBinary)
+	s.smooth.ConvertTo(&s.mask, gocv.MatTypeCV8U)
+	contours := gocv.FindContours(s.mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	var best image.Rectangle
+	var bestArea float64
+	for i := 0; i < contours.Size(); i++ {
+		if area := gocv.ContourArea(contours.At(i)); best.Empty() || area > bestArea {
+			best, bestArea = gocv.BoundingRect(contours.At(i)), area
+		}
+	}
+	if best.Empty() {
+		return whole
+	}
+
+	// scale back up from the small square to the frame
+	sx := float64(img.Cols()) / saliencySize
+	sy := float64(img.Rows()) / saliencySize
+	r := image.Rect(int(float64(best.Min.X)*sx), int(float64(best.Min.Y)*sy),
+		int(float64(best.Max.X)*sx), int(float64(best.Max.Y)*sy))
+	return growRect(r, int(saliencyMin*float64(img.Cols())), int(saliencyMin*float64(img.Rows()))).Intersect(whole)
+}
+
+// cartToPolar splits the complex spectrum into magnitude and angle.
+func (s *saliencyDetector) cartToPolar() {
+	planes := gocv.Split(s.complex)
+	defer func() {
+		for _, p := range planes {
+			p.Close()
+		}
+	}()
+	gocv.CartToPolar(planes[0], planes[1], &s.mag, &s.angle, false)
+}
+
+// growRect grows r about its center until it is at least w wide and h
+// high.
+func growRect(r image.Rectangle, w, h int) image.Rectangle {
+	if dx := w - r.Dx(); dx > 0 {
+		r.Min.X -= dx / 2
+		r.Max.X += dx - dx/2
+	}
+	if dy := h - r.Dy(); dy > 0 {
+		r.Min.Y -= dy / 2
+		r.Max.Y += dy - dy/2
+	}
+	return r
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	sessionsDir      = flag.String("sessions-dir", "sessions", "directory that sessions recorded with the record action are written to")
+	sessionPerFlight = flag.Bool("session-per-flight", false, "record each flight as its own session, from takeoff to landing")
+)
+
+// sessionFPS is the frame rate written into the session video. Frames are
+// written as they arrive, so index.csv has their true times.
+const sessionFPS = 15
+
+// sessionColumns are the columns of a session's index.csv.
+var sessionColumns = []string{
+	"unix_ms", "frame", "label", "score",
+	"phase", "mode", "estimated_altitude", "battery",
+	"pitch", "roll", "throttle", "yaw",
+}
+
+// A session is recorded into its own directory under -sessions-dir, named
+// for when it started, holding:
+//
+//	frames.avi     every frame, as the camera saw it, before the overlay
+//	index.csv      one row per frame, in sessionColumns order, with the
+//	               frame number in frames.avi, the classification and the
+//	               drone's state and command values when it was seen
+//	manifest.json  when the session started and stopped, how many
+//	               frames it has, what the files and columns are and the
+//	               tuning flags in use
+//
+// so that frames can be matched to what the drone was told to do by
+// their timestamps, for training and analysis.
+type sessionManifest struct {
+	Started time.Time         `json:"started"`
+	Stopped time.Time         `json:"stopped"`
+	Frames  int               `json:"frames"`
+	Video   string            `json:"video"`
+	Index   string            `json:"index"`
+	Columns []string          `json:"columns"`
+	Tuning  map[string]string `json:"tuning"`
+}
+
+// sessionSample is what is recorded alongside each frame.
+type sessionSample struct {
+	label    string
+	score    float32
+	state    droneState
+	commands [4]int
+}
+
+// sessionRecorder records sessions, started and stopped by toggle.
+type sessionRecorder struct {
+	parent string
+
+	sync.Mutex
+	dir      string
+	manifest sessionManifest
+	video    *gocv.VideoWriter
+	file     *os.File
+	index    *csv.Writer
+}
+
+func newSessionRecorder(parent string) *sessionRecorder {
+	return &sessionRecorder{parent: parent}
+}
+
+// toggle starts a new session, or stops the one being recorded.
+func (s *sessionRecorder) toggle() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.dir != "" {
+		s.stop()
+		return
+	}
+	if err := s.start(time.Now()); err != nil {
+		log.Println("session:", err)
+	}
+}
+
+func (s *sessionRecorder) start(now time.Time) error {
+	dir := filepath.Join(s.parent, now.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, "index.csv"))
+	if err != nil {
+		return err
+	}
+
+	s.dir, s.file, s.index = dir, f, csv.NewWriter(f)
+	s.index.Write(sessionColumns)
+	s.manifest = sessionManifest{
+		Started: now,
+		Video:   "frames.avi",
+		Index:   "index.csv",
+		Columns: sessionColumns,
+		Tuning:  make(map[string]string),
+	}
+	for _, tuning := range tuningFlags {
+		s.manifest.Tuning[tuning] = flag.Lookup(tuning).Value.String()
+	}
+	log.Println("session: recording to", dir)
+	return nil
+}
+
+// tookOff starts a session for the flight with -session-per-flight,
+// finishing any session already being recorded so that each flight is in
+// one of its own.
+func (s *sessionRecorder) tookOff(now time.Time) {
+	if !*sessionPerFlight {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if s.dir != "" {
+		s.stop()
+	}
+	if err := s.start(now); err != nil {
+		log.Println("session:", err)
+	}
+}
+
+// landed finishes the flight's session with -session-per-flight.
+func (s *sessionRecorder) landed() {
+	if !*sessionPerFlight {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if s.dir != "" {
+		s.stop()
+	}
+}
+
+// stop finishes the session, writing its manifest.
+func (s *sessionRecorder) stop() {
+	if s.video != nil {
+		s.video.Close()
+		s.video = nil
+	}
+	s.index.Flush()
+	s.file.Close()
+
+	s.manifest.Stopped = time.Now()
+	b, err := json.MarshalIndent(s.manifest, "", "    ")
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(s.dir, "manifest.json"), append(b, '\n'), 0644)
+	}
+	if err != nil {
+		log.Println("session:", err)
+	}
+	log.Printf("session: recorded %d frames to %v", s.manifest.Frames, s.dir)
+	s.dir = ""
+}
+
+// Close stops any session being recorded.
+func (s *sessionRecorder) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.dir != "" {
+		s.stop()
+	}
+	return nil
+}
+
+// record adds img to the session being recorded, if there is one. The
+// video is opened at the size of the first frame.
+func (s *sessionRecorder) record(img gocv.Mat, sample sessionSample, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.dir == "" {
+		return
+	}
+	if s.video == nil {
+		video, err := gocv.VideoWriterFile(filepath.Join(s.dir, s.manifest.Video), "MJPG", sessionFPS, img.Cols(), img.Rows(), true)
+		if err != nil {
+			log.Println("session:", err)
+			s.stop()
+			return
+		}
+		s.video = video
+	}
+	if err := s.video.Write(img); err != nil {
+		log.Println("session:", err)
+		return
+	}
+
+	st, c := sample.state, sample.commands
+	s.index.Write([]string{
+		strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10),
+		strconv.Itoa(s.manifest.Frames),
+		sample.label,
+		strconv.FormatFloat(float64(sample.score), 'f', 4, 32),
+		st.Phase, st.Mode,
+		strconv.FormatFloat(st.Altitude, 'f', 2, 64),
+		strconv.Itoa(st.Battery),
+		strconv.Itoa(c[pitchAxis]), strconv.Itoa(c[rollAxis]),
+		strconv.Itoa(c[throttleAxis]), strconv.Itoa(c[yawAxis]),
+	})
+	s.manifest.Frames++
+}
+
+// status describes the session being recorded for the overlay, or returns
+// "" when there is none.
+func (s *sessionRecorder) status() string {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.dir == "" {
+		return ""
+	}
+	return fmt.Sprintf("recording session: %d frames", s.manifest.Frames)
+}
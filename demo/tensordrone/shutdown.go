@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"gobot.io/x/gobot"
+)
+
+var shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "how long landing and releasing everything may take on Ctrl-C before exiting anyway")
+
+// landSettle is how long to leave the connection open after landing, for
+// the command to reach the drone, as the minidrone driver does when it is
+// halted.
+const landSettle = 500 * time.Millisecond
+
+// shutdown lands the drone and releases everything, once, when the program
+// is interrupted or terminated or the frame callback panics. It stands in
+// for gobot's own handling of Ctrl-C, which lands the drone only once the
+// devices before it have halted and has no timeout, so that a wedged
+// connection can neither keep the drone flying nor the program running.
+type shutdown struct {
+	drone   interface{ Land() error }
+	timeout time.Duration
+	// exit is os.Exit, for when a frame is still being handled after the
+	// timeout, and nothing can be released
+	exit func(code int)
+
+	once     sync.Once
+	done     chan struct{}
+	stopping int32
+	// commanding counts what may be commanding the drone, so that nothing
+	// else is sent once it is told to land. It is a count rather than a
+	// lock as control can be called within control, such as for a key
+	// pressed while the display loop runs.
+	commanding int32
+	// frames is held for reading while a frame is handled, so that nothing
+	// is released from under it, and idle is closed once it is held for
+	// good
+	frames sync.RWMutex
+	idle   chan struct{}
+
+	mu      sync.Mutex
+	tickers []*time.Ticker
+	stops   []func()
+	closers []func() error
+}
+
+func newShutdown(drone interface{ Land() error }, timeout time.Duration) *shutdown {
+	return &shutdown{
+		drone:   drone,
+		timeout: timeout,
+		exit:    os.Exit,
+		done:    make(chan struct{}),
+		idle:    make(chan struct{}),
+	}
+}
+
+// notify shuts down on SIGINT or SIGTERM. Any signal after the first is
+// ignored while the shutdown finishes.
+func (s *shutdown) notify() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range c {
+			s.run(sig.String())
+		}
+	}()
+}
+
+// isStopping reports whether the shutdown has started.
+func (s *shutdown) isStopping() bool {
+	return atomic.LoadInt32(&s.stopping) == 1
+}
+
+// control runs f, which may command the drone, unless the shutdown has
+// started. The drone is not told to land until f has returned.
+func (s *shutdown) control(f func()) {
+	atomic.AddInt32(&s.commanding, 1)
+	defer atomic.AddInt32(&s.commanding, -1)
+	if s.isStopping() {
+		return
+	}
+	f()
+}
+
+// every is gobot.Every, run under control and with the loop stopped on
+// shutdown.
+func (s *shutdown) every(d time.Duration, f func()) *time.Ticker {
+	ticker := gobot.Every(d, func() { s.control(f) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickers = append(s.tickers, ticker)
+	return ticker
+}
+
+// onStop adds something to stop on shutdown, before the drone is told to
+// land, such as a demo script flying it.
+func (s *shutdown) onStop(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stops = append(s.stops, f)
+}
+
+// onClose adds something to release on shutdown, after the drone has
+// landed and no frame is being handled. They are released in the reverse
+// order they were added in.
+func (s *shutdown) onClose(f func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closers = append(s.closers, f)
+}
+
+// frame reports whether a frame should be handled, and if so frameDone
+// must be called once it has been.
+func (s *shutdown) frame() bool {
+	if s.isStopping() {
+		return false
+	}
+	s.frames.RLock()
+	if s.isStopping() {
+		s.frames.RUnlock()
+		return false
+	}
+	return true
+}
+
+func (s *shutdown) frameDone() {
+	s.frames.RUnlock()
+}
+
+// recover shuts down after a panic in what, rather than letting it take
+// the program down with the drone still flying. It must be deferred.
+func (s *shutdown) recover(what string) {
+	if r := recover(); r != nil {
+		log.Printf("%v panicked: %v\n%s", what, r, debug.Stack())
+		// the panicking goroutine may be holding frames, so shut down
+		// from another
+		go s.run("panic in " + what)
+	}
+}
+
+// run stops everything that commands the drone, lands it and releases
+// everything else, the first time it is called, giving up after the
+// timeout. Later calls wait for the first to finish.
+func (s *shutdown) run(reason string) {
+	s.once.Do(func() {
+		log.Printf("shutting down: %v", reason)
+		atomic.StoreInt32(&s.stopping, 1)
+
+		s.mu.Lock()
+		for _, t := range s.tickers {
+			t.Stop()
+		}
+		stops, closers := s.stops, s.closers
+		s.mu.Unlock()
+		for _, stop := range stops {
+			stop()
+		}
+
+		// wait for the frame being handled, if any, before releasing
+		// anything it could be using
+		go func() {
+			s.frames.Lock()
+			close(s.idle)
+		}()
+
+		finished := make(chan struct{})
+		go func() {
+			defer close(finished)
+
+			// wait for a loop that is sending a command to finish, so that
+			// landing is the last thing the drone is told
+			for atomic.LoadInt32(&s.commanding) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			if err := s.drone.Land(); err != nil {
+				log.Println("shutdown: land:", err)
+			}
+			time.Sleep(landSettle)
+
+			<-s.idle
+			for i := len(closers) - 1; i >= 0; i-- {
+				if err := closers[i](); err != nil {
+					log.Println("shutdown:", err)
+				}
+			}
+		}()
+
+		select {
+		case <-finished:
+		case <-time.After(s.timeout):
+			log.Printf("shutdown: still not done after %v", s.timeout)
+		}
+		close(s.done)
+	})
+}
+
+// wait blocks until the shutdown has finished or timed out. Once it returns
+// no frame is being handled, so whatever frames use can be released. If
+// one is still being handled after the timeout, it exits instead.
+func (s *shutdown) wait() {
+	<-s.done
+	select {
+	case <-s.idle:
+	default:
+		log.Println("shutdown: a frame is still being handled, exiting without releasing it")
+		s.exit(1)
+	}
+}
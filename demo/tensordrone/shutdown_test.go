@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLander records what happens to it, and what else happens, in order.
+type fakeLander struct {
+	sync.Mutex
+	events []string
+	block  chan struct{}
+}
+
+func (d *fakeLander) record(event string) {
+	d.Lock()
+	defer d.Unlock()
+	d.events = append(d.events, event)
+}
+
+func (d *fakeLander) Land() error {
+	d.record("land")
+	if d.block != nil {
+		<-d.block
+	}
+	return nil
+}
+
+func (d *fakeLander) recorded() []string {
+	d.Lock()
+	defer d.Unlock()
+	return append([]string(nil), d.events...)
+}
+
+func TestShutdownLandsOnceInOrder(t *testing.T) {
+	drone := &fakeLander{}
+	s := newShutdown(drone, 5*time.Second)
+	s.exit = func(code int) { t.Errorf("exited with %d", code) }
+
+	landed := func() bool {
+		for _, e := range drone.recorded() {
+			if e == "land" {
+				return true
+			}
+		}
+		return false
+	}
+	s.every(time.Millisecond, func() {
+		if landed() {
+			drone.record("command after land")
+		}
+	})
+	s.onStop(func() { drone.record("stop script") })
+	s.onClose(func() error { drone.record("close first added"); return nil })
+	s.onClose(func() error { drone.record("close last added"); return nil })
+
+	// a frame being handled holds up the release, but not the landing
+	if !s.frame() {
+		t.Fatal("frame refused before the shutdown")
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.frameDone()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run("test")
+		}()
+	}
+	wg.Wait()
+	s.wait()
+
+	want := []string{"stop script", "land", "close last added", "close first added"}
+	got := drone.recorded()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	if s.frame() {
+		t.Error("frame accepted after the shutdown")
+	}
+	ran := false
+	s.control(func() { ran = true })
+	if ran {
+		t.Error("control ran after the shutdown")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	drone := &fakeLander{block: make(chan struct{})}
+	defer close(drone.block)
+	s := newShutdown(drone, 50*time.Millisecond)
+	exited := -1
+	s.exit = func(code int) { exited = code }
+	closed := false
+	s.onClose(func() error { closed = true; return nil })
+
+	// a frame that never finishes keeps anything from being released
+	if !s.frame() {
+		t.Fatal("frame refused before the shutdown")
+	}
+	s.run("test")
+	s.wait()
+
+	if exited != 1 {
+		t.Errorf("exit code %d, want 1", exited)
+	}
+	if closed {
+		t.Error("released while the drone was landing and a frame was handled")
+	}
+}
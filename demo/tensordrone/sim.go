@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"time"
+
+	"gobot.io/x/gobot"
+	"gobot.io/x/gobot/platforms/parrot/minidrone"
+	"gocv.io/x/gocv"
+)
+
+var simulate = flag.Bool("sim", false, "fly a simulated drone, shown from above in its own window, instead of a real one, for practice without hardware")
+
+// flyingDrone is what the demo needs of a drone: the commands to fly it
+// and the minidrone events it publishes.
+type flyingDrone interface {
+	gobot.Device
+	gobot.Eventer
+	droneCommands
+}
+
+const (
+	// simTick is how often the simulated drone moves.
+	simTick = 20 * time.Millisecond
+	// simLag is the time constant of the simulated drone speeding up and
+	// slowing down, as a real one does not change speed at once.
+	simLag = 0.3
+	// simTakeoff is how long the simulated drone takes to take off.
+	simTakeoff = time.Second
+	// simTrail is how many positions the trail behind the drone shows.
+	simTrail = 300
+	// simViewSize is the side of the top down view in pixels, and
+	// simPixelsPerMeter its scale.
+	simViewSize       = 480
+	simPixelsPerMeter = 40
+	// simCeiling is the altitude at the top of the altitude bar.
+	simCeiling = 5.0
+)
+
+// simDrone is a drone that only exists on screen. It takes the same
+// commands as the minidrone driver, moves from them with a little inertia
+// at -fly-speed, -yaw-rate and -climb-rate, and publishes the same events
+// as the driver does when it takes off, lands and so on.
+type simDrone struct {
+	name string
+	halt chan struct{}
+	gobot.Eventer
+
+	sync.Mutex
+	pitch, roll, yaw, throttle int
+	flying                     bool
+	x, y, z, heading           float64
+	vx, vy                     float64
+	trail                      []image.Point
+	view                       gocv.Mat
+}
+
+func newSimDrone() *simDrone {
+	s := &simDrone{
+		name:    gobot.DefaultName("Simulator"),
+		halt:    make(chan struct{}),
+		Eventer: gobot.NewEventer(),
+		view:    gocv.NewMatWithSize(simViewSize, simViewSize+60, gocv.MatTypeCV8UC3),
+	}
+	for _, e := range []string{minidrone.Battery, minidrone.Takeoff, minidrone.Hovering, minidrone.Flying,
+		minidrone.Landing, minidrone.Landed, minidrone.Emergency, minidrone.FlatTrimChange} {
+		s.AddEvent(e)
+	}
+	return s
+}
+
+// Name returns the drivers name.
+func (s *simDrone) Name() string { return s.name }
+
+// SetName sets the drivers name.
+func (s *simDrone) SetName(n string) { s.name = n }
+
+// Connection returns the drivers connection, which it has none of.
+func (s *simDrone) Connection() gobot.Connection { return nil }
+
+// Start starts the simulation.
+func (s *simDrone) Start() error {
+	go func() {
+		// the battery never runs down
+		s.Publish(minidrone.Battery, uint8(100))
+
+		last := time.Now()
+		for {
+			select {
+			case now := <-time.After(simTick):
+				s.step(now.Sub(last).Seconds())
+				last = now
+			case <-s.halt:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Halt stops the simulation.
+func (s *simDrone) Halt() error {
+	close(s.halt)
+	return nil
+}
+
+// step moves the drone on by dt seconds.
+func (s *simDrone) step(dt float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.flying {
+		s.vx, s.vy = 0, 0
+		return
+	}
+
+	s.heading = math.Mod(s.heading+float64(s.yaw)/100**yawRate*dt+360, 360)
+	rad := s.heading * math.Pi / 180
+
+	// the speed it is heading for, in the world's x (east) and y (north)
+	ahead := float64(s.pitch) / 100 * *flySpeed
+	right := float64(s.roll) / 100 * *flySpeed
+	tx := ahead*math.Sin(rad) + right*math.Cos(rad)
+	ty := ahead*math.Cos(rad) - right*math.Sin(rad)
+	k := 1 - math.Exp(-dt/simLag)
+	s.vx += (tx - s.vx) * k
+	s.vy += (ty - s.vy) * k
+
+	s.x += s.vx * dt
+	s.y += s.vy * dt
+	s.z = math.Max(0.2, s.z+float64(s.throttle)/100**climbRate*dt)
+
+	p := image.Pt(int(s.x*simPixelsPerMeter), int(-s.y*simPixelsPerMeter))
+	if len(s.trail) == 0 || s.trail[len(s.trail)-1] != p {
+		s.trail = append(s.trail, p)
+		if len(s.trail) > simTrail {
+			s.trail = s.trail[1:]
+		}
+	}
+}
+
+// after publishes event once d has passed, and then runs then, if any.
+func (s *simDrone) after(d time.Duration, event string, then func()) {
+	time.AfterFunc(d, func() {
+		if then != nil {
+			then()
+		}
+		s.Publish(event, nil)
+	})
+}
+
+func (s *simDrone) TakeOff() error {
+	s.Publish(minidrone.Takeoff, nil)
+	s.after(simTakeoff, minidrone.Hovering, func() {
+		s.Lock()
+		s.flying, s.z = true, takeoffHeight
+		s.Unlock()
+	})
+	return nil
+}
+
+func (s *simDrone) Land() error {
+	s.Publish(minidrone.Landing, nil)
+	s.after(simTakeoff, minidrone.Landed, func() {
+		s.Lock()
+		s.flying, s.z = false, 0
+		s.Unlock()
+	})
+	return nil
+}
+
+func (s *simDrone) Stop() error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.pitch, s.roll, s.yaw, s.throttle = 0, 0, 0, 0
+	return nil
+}
+
+func (s *simDrone) Emergency() error {
+	s.Lock()
+	s.flying, s.z = false, 0
+	s.Unlock()
+	s.Publish(minidrone.Emergency, nil)
+	return nil
+}
+
+func (s *simDrone) FlatTrim() error {
+	s.after(100*time.Millisecond, minidrone.FlatTrimChange, nil)
+	return nil
+}
+
+func (s *simDrone) HullProtection(protect bool) error                { return nil }
+func (s *simDrone) LightControl(id uint8, mode uint8, i uint8) error { return nil }
+
+// set sets one of the movement commands.
+func (s *simDrone) set(axis *int, val int) error {
+	s.Lock()
+	defer s.Unlock()
+
+	*axis = val
+	return nil
+}
+
+func (s *simDrone) Forward(val int) error          { return s.set(&s.pitch, val) }
+func (s *simDrone) Backward(val int) error         { return s.set(&s.pitch, -val) }
+func (s *simDrone) Right(val int) error            { return s.set(&s.roll, val) }
+func (s *simDrone) Left(val int) error             { return s.set(&s.roll, -val) }
+func (s *simDrone) Up(val int) error               { return s.set(&s.throttle, val) }
+func (s *simDrone) Down(val int) error             { return s.set(&s.throttle, -val) }
+func (s *simDrone) Clockwise(val int) error        { return s.set(&s.yaw, val) }
+func (s *simDrone) CounterClockwise(val int) error { return s.set(&s.yaw, -val) }
+
+// draw draws the drone from above, with north up and a grid a meter
+// apart, keeping the drone in the middle of the view, with its trail behind it
+// and an arrow for where it is heading, and its altitude as a bar down the
+// right hand side. The view is kept until the next call.
+func (s *simDrone) draw() gocv.Mat {
+	s.Lock()
+	defer s.Unlock()
+
+	img := &s.view
+	img.SetTo(gocv.NewScalar(40, 40, 40, 0))
+	grid := color.RGBA{80, 80, 80, 0}
+	white := color.RGBA{255, 255, 255, 0}
+	green := color.RGBA{0, 255, 0, 0}
+
+	// the world moves under the drone, which stays in the middle
+	me := image.Pt(int(s.x*simPixelsPerMeter), int(-s.y*simPixelsPerMeter))
+	center := image.Pt(simViewSize/2, simViewSize/2)
+	shift := center.Sub(me)
+
+	mx, my := me.X%simPixelsPerMeter, me.Y%simPixelsPerMeter
+	for g := -simPixelsPerMeter; g <= simViewSize+simPixelsPerMeter; g += simPixelsPerMeter {
+		gocv.Line(img, image.Pt(g-mx, 0), image.Pt(g-mx, simViewSize), grid, 1)
+		gocv.Line(img, image.Pt(0, g-my), image.Pt(simViewSize, g-my), grid, 1)
+	}
+	gocv.Circle(img, shift, 6, white, 1) // where it took off
+
+	for i := 1; i < len(s.trail); i++ {
+		gocv.Line(img, s.trail[i-1].Add(shift), s.trail[i].Add(shift), color.RGBA{0, 160, 255, 0}, 1)
+	}
+
+	rad := s.heading * math.Pi / 180
+	nose := center.Add(image.Pt(int(25*math.Sin(rad)), int(-25*math.Cos(rad))))
+	c := white
+	if s.flying {
+		c = green
+	}
+	gocv.Circle(img, center, 10, c, 2)
+	gocv.ArrowedLine(img, center, nose, c, 2)
+
+	// the altitude bar
+	bar := image.Rect(simViewSize+20, 20, simViewSize+40, simViewSize-20)
+	gocv.Rectangle(img, bar, white, 1)
+	h := int(math.Min(s.z/simCeiling, 1) * float64(bar.Dy()))
+	gocv.Rectangle(img, image.Rect(bar.Min.X, bar.Max.Y-h, bar.Max.X, bar.Max.Y), green, -1)
+	gocv.PutText(img, fmt.Sprintf("%.1fm", s.z), image.Pt(simViewSize+12, simViewSize-4), overlayFont, 0.9, white, 1)
+	gocv.PutText(img, fmt.Sprintf("heading %.0f", s.heading), image.Pt(10, 20), overlayFont, overlayScale, white, 1)
+
+	return s.view
+}
+
+// Close releases the view.
+func (s *simDrone) Close() error {
+	return s.view.Close()
+}
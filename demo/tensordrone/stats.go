@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// sessionStats accumulates counters over the run so that a summary can be
+// printed when the demo shuts down.
+type sessionStats struct {
+	sync.Mutex
+
+	started time.Time
+
+	takeoffs    int
+	landings    int
+	flightStart time.Time
+	flightTime  time.Duration
+
+	frames         int
+	inferences     int
+	inferenceTotal time.Duration
+	inferencePeak  time.Duration
+	labels         map[string]int
+}
+
+func newSessionStats() *sessionStats {
+	return &sessionStats{
+		started: time.Now(),
+		labels:  make(map[string]int),
+	}
+}
+
+// tookOff records that the drone has left the ground.
+func (s *sessionStats) tookOff() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.takeoffs++
+	if s.flightStart.IsZero() {
+		s.flightStart = time.Now()
+	}
+}
+
+// landed records that the drone is back on the ground.
+func (s *sessionStats) landed() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.landings++
+	if !s.flightStart.IsZero() {
+		s.flightTime += time.Since(s.flightStart)
+		s.flightStart = time.Time{}
+	}
+}
+
+// frame records a processed camera frame.
+func (s *sessionStats) frame() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.frames++
+}
+
+// classified records how long one classification took and what it found.
+func (s *sessionStats) classified(label string, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.inferences++
+	s.inferenceTotal += d
+	if d > s.inferencePeak {
+		s.inferencePeak = d
+	}
+	s.labels[label]++
+}
+
+// report writes the session summary to w.
+func (s *sessionStats) report(w io.Writer) {
+	s.Lock()
+	defer s.Unlock()
+
+	session := time.Since(s.started)
+	flight := s.flightTime
+	if !s.flightStart.IsZero() {
+		flight += time.Since(s.flightStart)
+	}
+
+	var fps float64
+	if session > 0 {
+		fps = float64(s.frames) / session.Seconds()
+	}
+
+	var avg time.Duration
+	if s.inferences > 0 {
+		avg = s.inferenceTotal / time.Duration(s.inferences)
+	}
+
+	top, topCount := "none", 0
+	for label, n := range s.labels {
+		if n > topCount || (n == topCount && label < top) {
+			top, topCount = label, n
+		}
+	}
+
+	fmt.Fprintln(w, "Session summary")
+	fmt.Fprintf(w, "  session duration:  %v\n", session.Round(time.Second))
+	fmt.Fprintf(w, "  flight time:       %v\n", flight.Round(time.Second))
+	fmt.Fprintf(w, "  takeoffs/landings: %d/%d\n", s.takeoffs, s.landings)
+	fmt.Fprintf(w, "  frames processed:  %d\n", s.frames)
+	fmt.Fprintf(w, "  average FPS:       %.1f\n", fps)
+	fmt.Fprintf(w, "  inference avg/max: %v/%v\n", avg.Round(time.Millisecond), s.inferencePeak.Round(time.Millisecond))
+	fmt.Fprintf(w, "  most seen label:   %v (%d)\n", top, topCount)
+}
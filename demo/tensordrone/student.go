@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"gobot.io/x/gobot"
+)
+
+var studentFile = flag.String("student", "", "joystick JSON file for a second controller that flies pitch and roll, leaving throttle and yaw to the first")
+
+// studentStick reads a second joystick. The gobot joystick adaptor only
+// ever opens the first joystick, and its driver takes every SDL event off
+// the queue, so the second controller's axes are polled instead and
+// published as events named after the axes in its mapping file.
+type studentStick struct {
+	name       string
+	connection gobot.Connection
+	configPath string
+	index      int
+	axes       map[string]int
+	joystick   *sdl.Joystick
+	halt       chan bool
+	gobot.Eventer
+}
+
+// newStudentStick returns a driver for the joystick at SDL index 1. It
+// shares the connection of the first joystick, which initializes SDL.
+func newStudentStick(a gobot.Connection, config string) *studentStick {
+	return &studentStick{
+		name:       gobot.DefaultName("Student"),
+		connection: a,
+		configPath: config,
+		index:      1,
+		axes:       make(map[string]int),
+		halt:       make(chan bool),
+		Eventer:    gobot.NewEventer(),
+	}
+}
+
+// Name returns the drivers name.
+func (s *studentStick) Name() string { return s.name }
+
+// SetName sets the drivers name.
+func (s *studentStick) SetName(n string) { s.name = n }
+
+// Connection returns the drivers connection.
+func (s *studentStick) Connection() gobot.Connection { return s.connection }
+
+// Start opens the second joystick and publishes its axes when they move.
+func (s *studentStick) Start() error {
+	b, err := ioutil.ReadFile(s.configPath)
+	if err != nil {
+		return err
+	}
+	var mapping joystickMapping
+	if err := json.Unmarshal(b, &mapping); err != nil {
+		return err
+	}
+	for _, a := range mapping.Axis {
+		s.axes[a.Name] = a.ID
+		s.AddEvent(a.Name)
+	}
+
+	if sdl.NumJoysticks() <= s.index {
+		return errors.New("No second joystick available for the student")
+	}
+	s.joystick = sdl.JoystickOpen(s.index)
+
+	go func() {
+		last := make(map[string]int16)
+		for {
+			for name, id := range s.axes {
+				val := s.joystick.Axis(id)
+				if old, ok := last[name]; !ok || val != old {
+					last[name] = val
+					s.Publish(name, val)
+				}
+			}
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-s.halt:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Halt stops polling and closes the joystick.
+func (s *studentStick) Halt() error {
+	if s.joystick == nil {
+		return nil
+	}
+	s.halt <- true
+	s.joystick.Close()
+	return nil
+}
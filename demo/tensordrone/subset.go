@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var labelSubset = flag.String("label-subset", "", "file of the labels of interest, one per line, so that the classifier reports the best of them, or other when the best match is something else")
+
+// otherLabel is what a frame is classified as with -label-subset when its
+// best match is not one of the labels of interest.
+const otherLabel = "other"
+
+// labelGroups are the labels of interest from a -label-subset file. Each
+// group is reported under one name and scores as its best member, so that
+// the many dog breeds in ImageNet can be reported as dog.
+type labelGroups struct {
+	names   []string
+	members [][]int
+}
+
+// readLabelSubset reads the -label-subset file at path, finding each label
+// in descriptions. Each line holds a label as it is in the descriptions
+// file, optionally followed by a tab and the name to report it under,
+// which several lines may share. It returns nil if path is empty.
+func readLabelSubset(path string, descriptions []string) (*labelGroups, error) {
+	if path == "" {
+		return nil, nil
+	}
+	lines, err := readDescriptions(path)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(descriptions))
+	for i, d := range descriptions {
+		index[strings.TrimSpace(d)] = i
+	}
+
+	g := &labelGroups{}
+	groups := make(map[string]int)
+	for n, line := range lines {
+		parts := strings.SplitN(line, "\t", 2)
+		label := strings.TrimSpace(parts[0])
+		if label == "" {
+			continue
+		}
+		i, ok := index[label]
+		if !ok {
+			return nil, fmt.Errorf("%v:%d: %q is not in the descriptions file, see -list-labels", path, n+1, label)
+		}
+		name := label
+		if len(parts) == 2 && strings.TrimSpace(parts[1]) != "" {
+			name = strings.TrimSpace(parts[1])
+		}
+
+		group, ok := groups[name]
+		if !ok {
+			group = len(g.names)
+			groups[name] = group
+			g.names = append(g.names, name)
+			g.members = append(g.members, nil)
+		}
+		g.members[group] = append(g.members[group], i)
+	}
+	if len(g.names) == 0 {
+		return nil, fmt.Errorf("%v: no labels of interest", path)
+	}
+	return g, nil
+}
+
+// remap turns the score for every description into one for each group,
+// followed by one for other, which scores as the best of the rest. So the
+// best of the new scores is a group only when the best of all of them was
+// one of its members.
+func (g *labelGroups) remap(scores []float32) []float32 {
+	grouped := make([]float32, len(g.names)+1)
+	in := make([]bool, len(scores))
+	for group, members := range g.members {
+		for _, i := range members {
+			if i >= len(scores) {
+				continue
+			}
+			in[i] = true
+			if scores[i] > grouped[group] {
+				grouped[group] = scores[i]
+			}
+		}
+	}
+
+	other := len(g.names)
+	for i, score := range scores {
+		if !in[i] && score > grouped[other] {
+			grouped[other] = score
+		}
+	}
+	return grouped
+}
+
+// label returns the name for position i of the remapped scores.
+func (g *labelGroups) label(i int) string {
+	if i < 0 || i > len(g.names) {
+		return "Unknown"
+	}
+	if i == len(g.names) {
+		return otherLabel
+	}
+	return g.names[i]
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+)
+
+var climbRate = flag.Float64("climb-rate", 0.7, "vertical speed of the drone in m/s at full throttle, used to estimate altitude")
+
+// takeoffHeight is roughly how high a minidrone hovers after taking off.
+const takeoffHeight = 1.0
+
+// altimeter estimates how high the drone is. The minidrone does not report
+// its altitude, so it is worked out from the throttle commands sent to it
+// and how long each was held. It drifts over a flight, so treat it as a
+// rough guide rather than a measurement.
+//
+// As the estimate only moves with the throttle commands, it can never show
+// the drone losing height it was not told to, so it cannot be used to
+// catch a fall and command a recovery. That needs a drone that reports a
+// measured altitude.
+type altimeter struct {
+	sync.Mutex
+	rate     float64
+	altitude float64
+	climb    int
+	since    time.Time
+}
+
+func newAltimeter(rate float64) *altimeter {
+	return &altimeter{rate: rate}
+}
+
+// throttle records that the drone was told to climb at climb percent of
+// full throttle from now on, negative for descending.
+func (a *altimeter) throttle(climb int, now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.integrate(now)
+	a.climb = climb
+}
+
+// set records a known altitude, such as on landing or after takeoff.
+func (a *altimeter) set(altitude float64, now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.altitude = altitude
+	a.climb = 0
+	a.since = now
+}
+
+// height returns the estimated altitude in meters.
+func (a *altimeter) height() float64 {
+	a.Lock()
+	defer a.Unlock()
+
+	a.integrate(time.Now())
+	return a.altitude
+}
+
+// integrate moves the estimate on to now at the current climb rate. The
+// lock must be held.
+func (a *altimeter) integrate(now time.Time) {
+	if !a.since.IsZero() {
+		a.altitude += float64(a.climb) / 100 * a.rate * now.Sub(a.since).Seconds()
+		if a.altitude < 0 {
+			a.altitude = 0
+		}
+	}
+	a.since = now
+}
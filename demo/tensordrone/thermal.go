@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	classifyEveryN = flag.Int("classify-every", 1, "only classify every Nth frame, showing the last result in between")
+	hotTemp        = flag.Float64("hot-temp", 0, "CPU temperature in °C at which to classify less often to let it cool, 0 for off")
+	coolTemp       = flag.Float64("cool-temp", 65, "CPU temperature in °C below which -hot-temp throttling eases off again")
+	tempSource     = flag.String("temp-source", "", "file to read the CPU temperature from in millidegrees, instead of looking in /sys/class/thermal")
+)
+
+// maxThrottle is the most that -hot-temp will multiply -classify-every by.
+const maxThrottle = 8
+
+// classifyEvery is how many frames apart classifications are run, which is
+// -classify-every raised by the thermal throttle while the CPU is hot.
+var classifyEvery int32 = 1
+
+// cpuTemp is the last CPU temperature read, in hundredths of a degree, or
+// -1 when it is not known.
+var cpuTemp int32 = -1
+
+// findTempSource returns the thermal zone most likely to be the CPU: the
+// first whose type names the CPU or its package, or else the first zone.
+func findTempSource() (string, error) {
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+	if len(zones) == 0 {
+		return "", errors.New("no CPU temperature is available, so -hot-temp does nothing")
+	}
+	for _, zone := range zones {
+		kind, err := ioutil.ReadFile(filepath.Join(zone, "type"))
+		if err != nil {
+			continue
+		}
+		k := strings.ToLower(string(kind))
+		if strings.Contains(k, "cpu") || strings.Contains(k, "pkg") || strings.Contains(k, "soc") {
+			return filepath.Join(zone, "temp"), nil
+		}
+	}
+	return filepath.Join(zones[0], "temp"), nil
+}
+
+// readTemp reads a temperature in °C from a file holding millidegrees,
+// as the Linux thermal zones do.
+func readTemp(path string) (float64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	milli, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", path, err)
+	}
+	return float64(milli) / 1000, nil
+}
+
+// thermalThrottle returns the classification cadence to use next, given
+// the cadence in use and the temperature. It doubles while at or above
+// hot, up to maxThrottle times base, and halves back towards base once
+// below cool, holding steady in between so that it does not flap.
+func thermalThrottle(every, base int, temp, hot, cool float64) int {
+	switch {
+	case temp >= hot && every < base*maxThrottle:
+		every *= 2
+		if every > base*maxThrottle {
+			every = base * maxThrottle
+		}
+	case temp < cool && every > base:
+		every /= 2
+		if every < base {
+			every = base
+		}
+	}
+	return every
+}
+
+// throttleStatus describes the thermal throttle for the overlay, or
+// returns "" when classification is running at the normal rate.
+func throttleStatus() string {
+	every := atomic.LoadInt32(&classifyEvery)
+	if int(every) <= *classifyEveryN {
+		return ""
+	}
+	return fmt.Sprintf("hot: %.0f°C, classifying every %d frames", float64(atomic.LoadInt32(&cpuTemp))/100, every)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"log"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	timelapsePath  = flag.String("timelapse", "", "write a timelapse of the video as shown, overlay and all, to this AVI file, for a summary of a long session")
+	timelapseEvery = flag.Duration("timelapse-every", 10*time.Second, "how often a frame is added to the -timelapse")
+)
+
+// timelapseFPS is the frame rate the timelapse plays back at, so that with
+// the default -timelapse-every an hour goes by in 36 seconds.
+const timelapseFPS = 10
+
+// timelapse writes the frame being shown to a video every so often. It is
+// nil when there is no -timelapse, and then writes nothing.
+type timelapse struct {
+	path  string
+	every time.Duration
+
+	sync.Mutex
+	next   time.Time
+	video  *gocv.VideoWriter
+	size   image.Point
+	sized  gocv.Mat
+	frames int
+}
+
+func newTimelapse(path string, every time.Duration) *timelapse {
+	if path == "" {
+		return nil
+	}
+	return &timelapse{path: path, every: every, sized: gocv.NewMat()}
+}
+
+// capture adds img to the timelapse if it is time for the next frame. The
+// video is opened at the size of the first frame, and later frames of
+// another size, such as with the history shown, are scaled to it.
+func (t *timelapse) capture(img gocv.Mat, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	if now.Before(t.next) {
+		return
+	}
+	t.next = now.Add(t.every)
+
+	if t.video == nil {
+		video, err := gocv.VideoWriterFile(t.path, "MJPG", timelapseFPS, img.Cols(), img.Rows(), true)
+		if err != nil {
+			log.Println("timelapse:", err)
+			// try again at the next frame rather than on every one
+			return
+		}
+		t.video, t.size = video, image.Pt(img.Cols(), img.Rows())
+	}
+
+	frame := img
+	if img.Cols() != t.size.X || img.Rows() != t.size.Y {
+		gocv.Resize(img, &t.sized, t.size, 0, 0, gocv.InterpolationArea)
+		frame = t.sized
+	}
+	if err := t.video.Write(frame); err != nil {
+		log.Println("timelapse:", err)
+		return
+	}
+	t.frames++
+}
+
+// Close finishes the timelapse.
+func (t *timelapse) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	t.sized.Close()
+	if t.video == nil {
+		return nil
+	}
+	log.Printf("timelapse: wrote %d frames to %v", t.frames, t.path)
+	return t.video.Close()
+}
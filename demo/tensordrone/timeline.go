@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var timelinePath = flag.String("timeline", "", "write classifications and drone commands to a single timestamped log at this path")
+
+// timeline is a log shared by the classifier and the drone commands, so
+// that what the drone saw can be lined up with what it did. Every entry
+// has a sequence number and the time since the log was opened, taken from
+// the monotonic clock.
+type timeline struct {
+	sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time
+	seq   uint64
+}
+
+// openTimeline creates the log at path, or returns nil if path is empty.
+func openTimeline(path string) (*timeline, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &timeline{file: f, w: bufio.NewWriter(f), start: time.Now()}
+	fmt.Fprintln(t.w, "seq,elapsed_ms,kind,detail")
+	return t, nil
+}
+
+// record adds an entry of the given kind to the log.
+func (t *timeline) record(kind, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.seq++
+	elapsed := float64(time.Since(t.start)) / float64(time.Millisecond)
+	fmt.Fprintf(t.w, "%d,%.3f,%v,%q\n", t.seq, elapsed, kind, fmt.Sprintf(format, args...))
+}
+
+// Close flushes and closes the log.
+func (t *timeline) Close() error {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.w.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
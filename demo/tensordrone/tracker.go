@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// objectTracker follows a selected object from frame to frame. It is a
+// FrameProcessor, so register it to have it track and outline the object.
+type objectTracker struct {
+	sync.Mutex
+	tracker gocv.Tracker
+
+	// pick is the region to start tracking on the next frame, if any
+	pick *image.Rectangle
+
+	tracking bool
+	box      image.Rectangle
+	frame    image.Point
+}
+
+// selectCenter starts tracking whatever is in the middle of the next frame.
+func (t *objectTracker) selectCenter() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.pick = &image.Rectangle{}
+}
+
+// selectRegion starts tracking whatever is in r on the next frame. An
+// empty r selects the middle of the frame, as selectCenter does.
+func (t *objectTracker) selectRegion(r image.Rectangle) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.pick = &r
+}
+
+// selecting reports whether an object will be selected on the next frame.
+func (t *objectTracker) selecting() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.pick != nil
+}
+
+// stop stops tracking.
+func (t *objectTracker) stop() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.pick = nil
+	t.release()
+}
+
+// release closes the tracker. The caller must hold the lock.
+func (t *objectTracker) release() {
+	if t.tracker != nil {
+		t.tracker.Close()
+		t.tracker = nil
+	}
+	t.tracking = false
+}
+
+// offset returns where the tracked object is across the frame, from -1 at
+// the left edge to 1 at the right, and whether an object is being tracked.
+func (t *objectTracker) offset() (float64, bool) {
+	x, _, ok := t.position()
+	return x, ok
+}
+
+// position returns where the tracked object is in the frame, from -1 at
+// the left and top edges to 1 at the right and bottom, and whether an
+// object is being tracked.
+func (t *objectTracker) position() (x, y float64, ok bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	if !t.tracking || t.frame.X == 0 || t.frame.Y == 0 {
+		return 0, 0, false
+	}
+	cx := float64(t.box.Min.X+t.box.Max.X) / 2
+	cy := float64(t.box.Min.Y+t.box.Max.Y) / 2
+	return 2*cx/float64(t.frame.X) - 1, 2*cy/float64(t.frame.Y) - 1, true
+}
+
+// Process tracks the object in img and outlines it.
+func (t *objectTracker) Process(img gocv.Mat, result ClassificationResult) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.frame = image.Pt(img.Cols(), img.Rows())
+	if t.pick != nil {
+		t.release()
+		box := *t.pick
+		if box.Empty() {
+			// a square a quarter of the frame height across, in the middle
+			size := t.frame.Y / 4
+			box = image.Rect(0, 0, size, size).Add(t.frame.Div(2).Sub(image.Pt(size/2, size/2)))
+		}
+		t.pick = nil
+		t.tracker = gocv.NewTrackerMIL()
+		if !t.tracker.Init(img, box) {
+			log.Println("could not start tracking the selected object")
+			t.release()
+			return
+		}
+		t.tracking, t.box = true, box
+	}
+	if !t.tracking {
+		return
+	}
+
+	box, ok := t.tracker.Update(img)
+	if !ok {
+		log.Println("lost the tracked object")
+		t.release()
+		return
+	}
+	t.box = box
+	gocv.Rectangle(&img, box, color.RGBA{0, 255, 255, 0}, 2)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+var trailLength = flag.Int("trail", 0, "draw a fading trail of the last N positions of the tracked object")
+
+// trail is a ring buffer of the most recent centroids of a tracked object.
+type trail struct {
+	points []image.Point
+	next   int
+	full   bool
+}
+
+func newTrail(n int) *trail {
+	return &trail{points: make([]image.Point, n)}
+}
+
+// add records the latest centroid, dropping the oldest once the trail is full.
+func (t *trail) add(p image.Point) {
+	if len(t.points) == 0 {
+		return
+	}
+	t.points[t.next] = p
+	t.next = (t.next + 1) % len(t.points)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// recent returns the centroids from oldest to newest.
+func (t *trail) recent() []image.Point {
+	if !t.full {
+		return append([]image.Point(nil), t.points[:t.next]...)
+	}
+	return append(append([]image.Point(nil), t.points[t.next:]...), t.points[:t.next]...)
+}
+
+// draw renders the trail on img, older points smaller and fainter.
+func (t *trail) draw(img *gocv.Mat) {
+	pts := t.recent()
+	for i, p := range pts {
+		age := float64(i+1) / float64(len(pts))
+		c := color.RGBA{0, uint8(255 * age), uint8(255 * age), 0}
+		gocv.Circle(img, p, 1+int(5*age), c, -1)
+		if i > 0 {
+			gocv.Line(img, pts[i-1], p, c, 1)
+		}
+	}
+}
+
+// centroid returns the center of the largest rectangle, if there is one.
+func centroid(rects []image.Rectangle) (image.Point, bool) {
+	var best image.Rectangle
+	for _, r := range rects {
+		if r.Dx()*r.Dy() > best.Dx()*best.Dy() {
+			best = r
+		}
+	}
+	if best.Empty() {
+		return image.Point{}, false
+	}
+	return image.Pt((best.Min.X+best.Max.X)/2, (best.Min.Y+best.Max.Y)/2), true
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	lang    = flag.String("lang", "", "show labels in this language, using the translations in -lang-dir")
+	langDir = flag.String("lang-dir", "translations", "directory of translation files named after each language, such as fr.txt")
+)
+
+// translations maps labels as they are in the descriptions file to the
+// same labels in another language.
+type translations map[string]string
+
+// readTranslations loads the translations for language from dir. Each
+// line of the file holds a label and its translation, separated by a tab.
+func readTranslations(dir, language string) (translations, error) {
+	path := filepath.Join(dir, language+".txt")
+	lines, err := readDescriptions(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := make(translations, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%v:%d: expected a label and its translation separated by a tab", path, i+1)
+		}
+		t[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return t, nil
+}
+
+// translate returns label in the chosen language, or label itself if
+// there is no translation for it.
+func (t translations) translate(label string) string {
+	if translated, ok := t[label]; ok && translated != "" {
+		return translated
+	}
+	return label
+}
@@ -0,0 +1,75 @@
+package main
+
+import "flag"
+
+var (
+	triggerLabel  = flag.String("trigger-label", "", "take off once the drone is armed and the classifier sees this label, such as banana")
+	landLabel     = flag.String("land-label", "", "land once the classifier sees this label while flying")
+	triggerScore  = flag.Float64("trigger-score", 0.8, "minimum score for -trigger-label and -land-label to count")
+	triggerFrames = flag.Int("trigger-frames", 5, "how many classifications in a row must see a -trigger-label or -land-label before the drone acts on it")
+)
+
+// triggerRequests carries the action, "takeoff" or "land", that a trigger
+// label asks for, to be run alongside the controller's actions.
+var triggerRequests = make(chan string, 1)
+
+// triggerWatcher takes off or lands when the classifier has seen a
+// trigger label with a high enough score for several classifications in a
+// row, so that something passing by the camera does not set it off.
+type triggerWatcher struct {
+	takeoff, land string
+	score         float32
+	frames        int
+
+	label string
+	seen  int
+}
+
+// newTriggerWatcher returns nil if there are no trigger labels.
+func newTriggerWatcher(takeoff, land string, score float64, frames int) *triggerWatcher {
+	if takeoff == "" && land == "" {
+		return nil
+	}
+	if frames < 1 {
+		frames = 1
+	}
+	return &triggerWatcher{takeoff: takeoff, land: land, score: float32(score), frames: frames}
+}
+
+// observe counts a classification towards its trigger, asking for the
+// trigger's action after -trigger-frames classifications in a row that see
+// it. Takeoff is asked for again after every -trigger-frames more, so that
+// a trigger held up before the drone is armed still counts once it is, and
+// does nothing when the drone is not ready for it. Land is asked for once
+// each time its label is seen, as asking again would cut short centering
+// over the landing pad.
+func (t *triggerWatcher) observe(label string, score float32) {
+	if t == nil {
+		return
+	}
+
+	var action string
+	switch {
+	case score < t.score:
+	case label == t.takeoff:
+		action = "takeoff"
+	case label == t.land:
+		action = "land"
+	}
+	if action == "" {
+		t.label, t.seen = "", 0
+		return
+	}
+	if label != t.label {
+		t.label, t.seen = label, 0
+	}
+	t.seen++
+	if t.seen%t.frames != 0 || action == "land" && t.seen != t.frames {
+		return
+	}
+
+	select {
+	case triggerRequests <- action:
+	default:
+	}
+}
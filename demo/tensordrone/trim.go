@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+)
+
+var requireTrim = flag.Bool("require-trim", true, "refuse to take off until the trim button has been used to flat trim the drone")
+
+// trimShown is how long the flat trim confirmation stays on screen.
+const trimShown = 3 * time.Second
+
+// flatTrim tracks whether the pilot has flat trimmed the drone, which
+// calibrates it to treat its current position as level. The driver does
+// not report the drone's attitude, so there is no checking that it really
+// is level before it trims or takes off; that is left to the pilot.
+type flatTrim struct {
+	sync.Mutex
+	requested bool
+	done      bool
+	at        time.Time
+}
+
+// request records that the drone has been told to flat trim.
+func (t *flatTrim) request() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.requested = true
+}
+
+// changed records the drone reporting a flat trim. The driver also trims
+// when it connects, which does not count until the pilot has asked for it.
+func (t *flatTrim) changed(now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.requested {
+		t.requested = false
+		t.done = true
+		t.at = now
+	}
+}
+
+// ready reports whether the drone may take off.
+func (t *flatTrim) ready() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.done || !*requireTrim
+}
+
+// status returns the message to show the pilot about the flat trim, if any.
+func (t *flatTrim) status(now time.Time) string {
+	t.Lock()
+	defer t.Unlock()
+
+	switch {
+	case t.requested:
+		return "flat trimming..."
+	case t.done && now.Sub(t.at) < trimShown:
+		return "flat trim done"
+	case !t.done && *requireTrim:
+		return "place the drone on a level surface and press trim"
+	}
+	return ""
+}
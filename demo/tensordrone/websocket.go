@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var wsAddr = flag.String("ws", "", "serve classification changes as JSON over a WebSocket at ws://ADDR/classifications, such as :8080, for a live web dashboard")
+
+// wsGUID is the key suffix of the WebSocket handshake, from RFC 6455.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsBacklog is how many messages a slow client may fall behind by before
+// it misses them.
+const wsBacklog = 16
+
+// wsMessage is the JSON sent to dashboards for each classification change.
+type wsMessage struct {
+	Label string    `json:"label"`
+	Score float32   `json:"score"`
+	Time  time.Time `json:"time"`
+}
+
+// wsServer pushes classification changes to every connected WebSocket
+// client. Only the little of the protocol needed to send text messages is
+// spoken, to keep the demo free of extra dependencies.
+type wsServer struct {
+	sync.Mutex
+	clients map[chan []byte]bool
+	last    string
+}
+
+// newWSServer starts serving on addr, or returns nil if addr is empty.
+func newWSServer(addr string) (*wsServer, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &wsServer{clients: make(map[chan []byte]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/classifications", s.serveWS)
+	mux.HandleFunc("/", serveDashboard)
+	go func() {
+		log.Println("ws:", http.Serve(l, mux))
+	}()
+	log.Printf("serving classifications on ws://%v/classifications", l.Addr())
+	return s, nil
+}
+
+// send sends label and score to every client, if label is not the one
+// last sent.
+func (s *wsServer) send(label string, score float32) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if label == s.last {
+		return
+	}
+	s.last = label
+
+	msg, err := json.Marshal(wsMessage{Label: label, Score: score, Time: time.Now()})
+	if err != nil {
+		return
+	}
+	for client := range s.clients {
+		select {
+		case client <- msg:
+		default:
+		}
+	}
+}
+
+// serveWS upgrades the request to a WebSocket and sends it messages until
+// the client goes away.
+func (s *wsServer) serveWS(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if key == "" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "expected a WebSocket", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade the connection", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %v\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	client := make(chan []byte, wsBacklog)
+	s.Lock()
+	s.clients[client] = true
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		delete(s.clients, client)
+		s.Unlock()
+	}()
+
+	// anything the client sends is read and ignored, until it closes
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		discardFrames(rw.Reader)
+	}()
+
+	for {
+		select {
+		case msg := <-client:
+			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if _, err := conn.Write(wsTextFrame(msg)); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// wsTextFrame returns msg as a single unmasked text frame, as a server
+// sends them.
+func wsTextFrame(msg []byte) []byte {
+	frame := []byte{0x81}
+	switch n := len(msg); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	return append(frame, msg...)
+}
+
+// discardFrames reads frames from a client until it sends a close frame or
+// the connection fails.
+func discardFrames(r *bufio.Reader) {
+	var header [2]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		if header[0]&0x0f == 0x8 {
+			return
+		}
+
+		n := uint64(header[1] & 0x7f)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(r, ext[:]); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		// client frames are masked with a four byte key
+		if header[1]&0x80 != 0 {
+			n += 4
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(n)); err != nil {
+			return
+		}
+	}
+}
+
+// serveDashboard serves a page that shows the classifications as they
+// arrive, as a starting point for a dashboard of your own.
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, dashboardPage)
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head><title>tensordrone</title></head>
+<body style="font-family: sans-serif; font-size: 3em; text-align: center">
+<p id="label">waiting for the drone...</p>
+<p id="score"></p>
+<script>
+var ws = new WebSocket("ws://" + location.host + "/classifications");
+ws.onmessage = function(e) {
+	var m = JSON.parse(e.data);
+	document.getElementById("label").textContent = m.label;
+	document.getElementById("score").textContent = Math.round(m.score * 100) + "%";
+};
+ws.onclose = function() {
+	document.getElementById("label").textContent = "disconnected";
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"gobot.io/x/gobot"
+	"gocv.io/x/gocv"
+)
+
+var reopenWindow = flag.Bool("reopen-window", true, "open the video window again if it is closed during the demo, rather than carrying on without it")
+
+// maxWindowReopens is how many times a window is opened again after being
+// closed before it is given up on, in case something keeps closing it.
+const maxWindowReopens = 3
+
+// videoWindow is a window driver that notices when its window has been
+// closed, which would otherwise leave the demo running, and the drone
+// flying, with nothing to see it by. It opens the window again, or with
+// -reopen-window=false, carries on without one, still recording,
+// classifying and flying from the joystick.
+type videoWindow struct {
+	name   string
+	window *gocv.Window
+
+	// seen is whether the window has been seen open, as not every
+	// OpenCV backend can tell, and those that cannot must not be taken to
+	// have closed it
+	seen     bool
+	reopened int
+	headless bool
+}
+
+func newVideoWindow(name string) *videoWindow {
+	return &videoWindow{name: name}
+}
+
+// Name returns the drivers name.
+func (w *videoWindow) Name() string { return w.name }
+
+// SetName sets the drivers name.
+func (w *videoWindow) SetName(n string) { w.name = n }
+
+// Connection returns the drivers connection, which it has none of.
+func (w *videoWindow) Connection() gobot.Connection { return nil }
+
+// Start opens the window.
+func (w *videoWindow) Start() error {
+	w.window = gocv.NewWindow(w.name)
+	return nil
+}
+
+// Halt closes the window.
+func (w *videoWindow) Halt() error {
+	if w.headless {
+		return nil
+	}
+	return w.window.Close()
+}
+
+// ShowImage shows img in the window, first opening it again if it has
+// been closed.
+func (w *videoWindow) ShowImage(img gocv.Mat) {
+	if w.headless {
+		return
+	}
+	if w.closed() {
+		w.window.Close()
+		if !*reopenWindow || w.reopened == maxWindowReopens {
+			log.Printf("%v window closed, carrying on without it", w.name)
+			w.headless = true
+			return
+		}
+		w.reopened++
+		w.seen = false
+		log.Printf("%v window closed, opening it again", w.name)
+		w.window = gocv.NewWindow(w.name)
+	}
+	w.window.IMShow(img)
+}
+
+// closed reports whether the window was open and has since been closed.
+func (w *videoWindow) closed() bool {
+	visible := w.window.GetWindowProperty(gocv.WindowPropertyVisible) >= 1
+	if visible {
+		w.seen = true
+	}
+	return w.seen && !visible
+}
+
+// WaitKey gives the windows a chance to redraw, and returns the key
+// pressed in them, if any.
+func (w *videoWindow) WaitKey(pause int) int {
+	return gocv.WaitKey(pause)
+}